@@ -41,7 +41,7 @@ func BenchmarkMatchLeaf(b *testing.B) {
 	b.ReportAllocs()
 
 	for i := 0; i < b.N; i++ {
-		parent.matchLeaf(GET)
+		parent.matchLeaf(nil, GET)
 	}
 }
 
@@ -71,7 +71,7 @@ func BenchmarkHadorServe(b *testing.B) {
 	resp := NewResponseWriter(recorder)
 
 	ctx := newContext(h.Logger)
-	ctx.params = make(Params, h.root.findMaxParams())
+	ctx.params = make(Params, h.routes().root.findMaxParams())
 
 	ctx.reset(resp, req)
 
@@ -105,6 +105,40 @@ func Benchmark5Param(b *testing.B) {
 	runRequest(b, h, "GET", "/a/b/c/d/e")
 }
 
+func BenchmarkGithubStatic(b *testing.B) {
+	h := newGithubHador(func(*Context) {})
+
+	runRequest(b, h, "GET", "/user/repos")
+}
+
+func BenchmarkGithubParam(b *testing.B) {
+	h := newGithubHador(func(*Context) {})
+
+	runRequest(b, h, "GET", "/repos/julienschmidt/httprouter/stargazers")
+}
+
+func TestServeZeroAllocs(t *testing.T) {
+	h := newGithubHador(func(*Context) {})
+	h.Get("/files/{name}.{ext}", func(*Context) {})
+	h.Get("/static/{filepath*}", func(*Context) {})
+
+	for _, path := range []string{
+		"/user/repos",
+		"/repos/julienschmidt/httprouter/stargazers",
+		"/files/report.tar.gz",
+		"/static/css/main.css",
+	} {
+		req, _ := http.NewRequest("GET", path, nil)
+		resp := httptest.NewRecorder()
+		allocs := testing.AllocsPerRun(100, func() {
+			h.ServeHTTP(resp, req)
+		})
+		if allocs != 0 {
+			t.Errorf("GET %s: %v allocs per request, want 0", path, allocs)
+		}
+	}
+}
+
 func BenchmarkCombine2Filters(b *testing.B) {
 	f1 := FilterFunc(func(ctx *Context, next Handler) { next.Serve(ctx) })
 	f2 := FilterFunc(func(ctx *Context, next Handler) { next.Serve(ctx) })
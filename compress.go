@@ -0,0 +1,279 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Encoder creates a writer compressing into w at level, which is
+// flate.DefaultCompression unless configured.
+type Encoder func(w io.Writer, level int) (io.WriteCloser, error)
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]Encoder{
+		"gzip": func(w io.Writer, level int) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+		"deflate": func(w io.Writer, level int) (io.WriteCloser, error) {
+			return zlib.NewWriterLevel(w, level)
+		},
+	}
+)
+
+// RegisterEncoder registers Encoder of content coding, e.g. "br" with a brotli
+// implementation, for Compression.
+func RegisterEncoder(coding string, encoder Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[coding] = encoder
+}
+
+func lookupEncoder(coding string) Encoder {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	return encoders[coding]
+}
+
+// Compression is a Filter compressing responses by the content coding negotiated
+// with Accept-Encoding.
+type Compression struct {
+	// Level is passed to Encoder.
+	Level int
+	// MinSize is the min size of bodies to compress. Bodies flushed before reaching
+	// it are compressed anyway.
+	MinSize int
+	// Encodings are content codings in order of preference, only registered ones
+	// are offered.
+	Encodings []string
+	// SkipTypes are media types not to compress, e.g. "image/png" or "video/*".
+	SkipTypes []string
+}
+
+// NewCompression creates new Compression instance with defaults.
+func NewCompression() *Compression {
+	return &Compression{
+		Level:     flate.DefaultCompression,
+		MinSize:   1024,
+		Encodings: []string{"br", "gzip", "deflate"},
+		SkipTypes: []string{
+			"image/*", "video/*", "audio/*", "font/woff", "font/woff2",
+			"application/zip", "application/gzip", "application/x-gzip",
+			"application/x-bzip2", "application/x-7z-compressed", "application/x-rar-compressed",
+			"application/pdf", "application/octet-stream",
+		},
+	}
+}
+
+// Filter implements Filter interface
+func (c *Compression) Filter(ctx *Context, next Handler) {
+	header := ctx.Response.Header()
+	header.Add("Vary", "Accept-Encoding")
+	req := ctx.Request
+	if req.Method == "HEAD" || headerHasToken(req.Header, "Connection", "upgrade") {
+		next.Serve(ctx)
+		return
+	}
+	var offers []string
+	for _, coding := range c.Encodings {
+		if lookupEncoder(coding) != nil {
+			offers = append(offers, coding)
+		}
+	}
+	coding := negotiateEncoding(req.Header.Get("Accept-Encoding"), offers...)
+	if coding == "" {
+		next.Serve(ctx)
+		return
+	}
+	cw := &compressWriter{ResponseWriter: ctx.Response, c: c, coding: coding}
+	ctx.Response = cw
+	defer func() {
+		ctx.Response = cw.ResponseWriter
+	}()
+	next.Serve(ctx)
+	if err := cw.close(); err != nil {
+		ctx.Logger.Error("failed to compress response: %s", err)
+	}
+}
+
+func (c *Compression) skipType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	return len(c.SkipTypes) > 0 && matchMediaTypes(c.SkipTypes, mediaType)
+}
+
+// compressWriter holds the response until it's decided whether to compress, by
+// status, headers and size of the body.
+type compressWriter struct {
+	ResponseWriter
+	c      *Compression
+	coding string
+
+	status  int
+	size    int
+	buf     []byte
+	decided bool
+	enc     io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status != 0 {
+		return
+	}
+	cw.status = status
+	// no body to compress
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified ||
+		status == http.StatusPartialContent {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	cw.size += len(p)
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.c.MinSize {
+			return len(p), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *compressWriter) WriteString(s string) (int, error) {
+	return cw.Write([]byte(s))
+}
+
+func (cw *compressWriter) Status() int {
+	return cw.status
+}
+
+func (cw *compressWriter) Written() bool {
+	return cw.status != 0
+}
+
+// Size returns the size of the body before compressed.
+func (cw *compressWriter) Size() int {
+	return cw.size
+}
+
+func (cw *compressWriter) Flush() {
+	if cw.status == 0 {
+		return
+	}
+	if !cw.decided {
+		cw.decide(true)
+	}
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	cw.ResponseWriter.Flush()
+}
+
+func (cw *compressWriter) hijacked(status int) {
+	cw.status = status
+	cw.decided = true
+	recordHijacked(cw.ResponseWriter, status)
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	return hijacker.Hijack()
+}
+
+// decide writes the held status and body, compressing the rest if compress and
+// the response is compressible.
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+	header := cw.Header()
+	if compress {
+		if header.Get("Content-Type") == "" && len(cw.buf) > 0 {
+			// sniff before compressed, or net/http would sniff the compressed bytes
+			header.Set("Content-Type", http.DetectContentType(cw.buf))
+		}
+		compress = header.Get("Content-Encoding") == "" && header.Get("Content-Range") == "" &&
+			!cw.c.skipType(header.Get("Content-Type"))
+	}
+	if compress {
+		enc, err := lookupEncoder(cw.coding)(cw.ResponseWriter, cw.c.Level)
+		if err != nil {
+			return err
+		}
+		cw.enc = enc
+		header.Set("Content-Encoding", cw.coding)
+		header.Del("Content-Length")
+		// compressed bodies aren't byte-for-byte identical to the tagged one
+		if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			header.Set("ETag", "W/"+etag)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close writes what's held, and finishes compression.
+func (cw *compressWriter) close() error {
+	if cw.status == 0 {
+		return nil
+	}
+	if !cw.decided {
+		if err := cw.decide(len(cw.buf) >= cw.c.MinSize); err != nil {
+			return err
+		}
+	}
+	if cw.enc != nil {
+		return cw.enc.Close()
+	}
+	return nil
+}
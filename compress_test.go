@@ -0,0 +1,157 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompression(t *testing.T) {
+	convey.Convey("Given Hador with Compression", t, func() {
+		h := New()
+		h.Before(NewCompression())
+		large := strings.Repeat("hador ", 500)
+		var status, size int
+		h.Get("/large", func(ctx *Context) {
+			ctx.SetHeader("Content-Type", "text/plain")
+			ctx.WriteString(large, http.StatusCreated)
+			status, size = ctx.Response.Status(), ctx.Response.Size()
+		})
+		h.Get("/small", func(ctx *Context) {
+			ctx.WriteString("small")
+		})
+		h.Get("/image", func(ctx *Context) {
+			ctx.SetHeader("Content-Type", "image/png")
+			ctx.WriteString(large)
+		})
+		h.Get("/sniffed", func(ctx *Context) {
+			ctx.WriteString("<html>" + large + "</html>")
+		})
+		h.Get("/empty", func(ctx *Context) {
+			ctx.WriteHeader(http.StatusNoContent)
+		})
+		h.Get("/stream", func(ctx *Context) {
+			s := ctx.SSE()
+			s.Send(Event{Data: "hello"})
+			s.Send(Event{Data: "world"})
+		})
+		serve := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			if acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", acceptEncoding)
+			}
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+		gunzip := func(r io.Reader) string {
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				return err.Error()
+			}
+			data, _ := io.ReadAll(zr)
+			return string(data)
+		}
+
+		convey.Convey("large bodies are compressed", func() {
+			resp := serve("/large", "deflate;q=0.5, gzip")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusCreated)
+			convey.So(resp.Header().Get("Content-Encoding"), convey.ShouldEqual, "gzip")
+			convey.So(resp.Header().Get("Vary"), convey.ShouldEqual, "Accept-Encoding")
+			convey.So(resp.Body.Len(), convey.ShouldBeLessThan, len(large))
+			convey.So(gunzip(resp.Body), convey.ShouldEqual, large)
+			convey.So(status, convey.ShouldEqual, http.StatusCreated)
+			convey.So(size, convey.ShouldEqual, len(large))
+
+			resp = serve("/large", "deflate, gzip;q=0.5")
+			convey.So(resp.Header().Get("Content-Encoding"), convey.ShouldEqual, "deflate")
+			zr, _ := zlib.NewReader(resp.Body)
+			data, _ := io.ReadAll(zr)
+			convey.So(string(data), convey.ShouldEqual, large)
+
+			resp = serve("/sniffed", "*")
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldEqual, "text/html; charset=utf-8")
+			convey.So(resp.Header().Get("Content-Encoding"), convey.ShouldEqual, "gzip")
+		})
+
+		convey.Convey("others are sent as is", func() {
+			for _, c := range []struct{ path, accept string }{
+				{"/large", ""}, {"/large", "gzip;q=0, identity"}, {"/small", "gzip"}, {"/image", "gzip"},
+			} {
+				resp := serve(c.path, c.accept)
+				convey.So(resp.Header().Get("Content-Encoding"), convey.ShouldBeEmpty)
+				convey.So(resp.Header().Get("Vary"), convey.ShouldEqual, "Accept-Encoding")
+			}
+			resp := serve("/empty", "gzip")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusNoContent)
+			convey.So(resp.Header().Get("Content-Encoding"), convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("flushed bodies are compressed", func() {
+			resp := serve("/stream", "gzip")
+			convey.So(resp.Flushed, convey.ShouldBeTrue)
+			convey.So(resp.Header().Get("Content-Encoding"), convey.ShouldEqual, "gzip")
+			convey.So(gunzip(resp.Body), convey.ShouldEqual, "data: hello\n\ndata: world\n\n")
+		})
+	})
+}
+
+func TestStaticPrecompressed(t *testing.T) {
+	convey.Convey("Given Static serving precompressed files", t, func() {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "app.js"), []byte("plain"), 0644)
+		os.WriteFile(filepath.Join(dir, "app.js.gz"), []byte("gzipped"), 0644)
+		os.WriteFile(filepath.Join(dir, "app.js.br"), []byte("brotli"), 0644)
+		h := New()
+		h.Before(NewCompression())
+		s := NewStatic(http.Dir(dir))
+		s.Precompressed = true
+		h.Before(s)
+		serve := func(acceptEncoding string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/app.js", nil)
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := serve("gzip, br")
+		convey.So(resp.Header().Get("Content-Encoding"), convey.ShouldEqual, "br")
+		convey.So(resp.Header().Get("Content-Type"), convey.ShouldStartWith, "text/javascript")
+		convey.So(resp.Header().Get("Vary"), convey.ShouldEqual, "Accept-Encoding")
+		convey.So(resp.Body.String(), convey.ShouldEqual, "brotli")
+
+		resp = serve("gzip")
+		convey.So(resp.Header().Get("Content-Encoding"), convey.ShouldEqual, "gzip")
+		convey.So(resp.Body.String(), convey.ShouldEqual, "gzipped")
+
+		resp = serve("")
+		convey.So(resp.Header().Get("Content-Encoding"), convey.ShouldBeEmpty)
+		convey.So(resp.Body.String(), convey.ShouldEqual, "plain")
+	})
+}
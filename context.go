@@ -20,6 +20,7 @@ package hador
 import (
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -37,6 +38,24 @@ type Context struct {
 	errHandlers   map[int]func(...interface{})
 	Err4XXHandler func(int, ...interface{})
 	Err5XXHandler func(int, ...interface{})
+	errorHandlers []*ErrorHandlers
+	renderers     *Renderers
+	decoders      *Decoders
+	templates     *Templates
+
+	maxBodySize  int64
+	strictBody   bool
+	bodyPrepared bool
+	bodyErr      error
+
+	upload    *Upload
+	formFiles map[string][]*FormFile
+	formErr   error
+	tempFiles []string
+	sse       *SSE
+	upgrader  *Upgrader
+
+	preconditionsChecked bool
 
 	path string
 }
@@ -55,15 +74,39 @@ func (ctx *Context) reset(w ResponseWriter, req *http.Request) {
 	ctx.errHandlers = nil
 	ctx.Err4XXHandler = nil
 	ctx.Err5XXHandler = nil
+	ctx.errorHandlers = ctx.errorHandlers[:0]
+	ctx.maxBodySize = 0
+	ctx.strictBody = false
+	ctx.bodyPrepared = false
+	ctx.bodyErr = nil
+	ctx.upload = nil
+	ctx.formFiles = nil
+	ctx.formErr = nil
+	ctx.sse = nil
+	ctx.upgrader = nil
+	ctx.preconditionsChecked = false
 }
 
 // OnError handles http error by calling handler registered in SetErrorHandler methods.
 // If no handler registered with this status and noting written yet, http.Error would be used.
 func (ctx *Context) OnError(status int, args ...interface{}) {
+	ctx.onError(status, nil, args)
+}
+
+// onError is OnError with err hidden from args, which is passed to ErrorHandlers only.
+func (ctx *Context) onError(status int, err error, args []interface{}) {
 	// do nothing if not an error
 	if status < 400 {
 		return
 	}
+	e := findError(args)
+	if e != nil && !ctx.Response.Written() {
+		for key, values := range e.Headers {
+			for _, value := range values {
+				ctx.Response.Header().Add(key, value)
+			}
+		}
+	}
 	// try to use custom error handler
 	if ctx.errHandlers != nil {
 		if h, ok := ctx.errHandlers[status]; ok {
@@ -81,6 +124,20 @@ func (ctx *Context) OnError(status int, args ...interface{}) {
 		return
 	}
 
+	if len(ctx.errorHandlers) > 0 {
+		if err == nil {
+			err = findAnyError(args, status)
+		}
+		if h := ctx.resolveErrorHandler(status, err); h != nil {
+			h(ctx, status, err)
+			return
+		}
+	}
+
+	if e != nil && !ctx.Response.Written() {
+		ctx.renderProblem(status, e)
+		return
+	}
 	if !ctx.Response.Written() {
 		text := http.StatusText(status)
 		if len(args) > 0 {
@@ -90,6 +147,49 @@ func (ctx *Context) OnError(status int, args ...interface{}) {
 	}
 }
 
+// HandleError responds err by OnError. Statuses of Error and HTTPError are kept, and
+// any other error is logged and responded as 500 without being exposed.
+func (ctx *Context) HandleError(err error) {
+	if err == nil {
+		return
+	}
+	if status, ok := errorStatus(err); ok {
+		var e *Error
+		if errors.As(err, &e) {
+			ctx.OnError(status, e)
+		} else {
+			ctx.OnError(status, err)
+		}
+		return
+	}
+	ctx.Logger.Error("unexpected error: %s", err)
+	ctx.onError(http.StatusInternalServerError, err, nil)
+}
+
+func findError(args []interface{}) *Error {
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			var e *Error
+			if errors.As(err, &e) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func (ctx *Context) renderProblem(status int, e *Error) {
+	data, err := json.Marshal(e.Problem(status, ctx.Request.URL.RequestURI()))
+	if err != nil {
+		ctx.Logger.Error("failed to render problem: %s", err)
+		http.Error(ctx.Response, http.StatusText(status), status)
+		return
+	}
+	ctx.SetHeader("Content-Type", contentTypeProblemJSON)
+	ctx.SetHeader("X-Content-Type-Options", "nosniff")
+	ctx.WriteStatus(append(data, '\n'), status)
+}
+
 // SetErrorHandler sets custom handler for each http error
 func (ctx *Context) SetErrorHandler(status int, handler func(...interface{})) {
 	if ctx.errHandlers == nil {
@@ -202,6 +302,8 @@ func (ctx *Context) WriteString(s string, status ...int) (n int, err error) {
 const (
 	contentTypeJSON = "application/json; charset=utf-8"
 	contentTypeXML  = "application/xml; charset=utf-8"
+
+	contentTypeProblemJSON = "application/problem+json"
 )
 
 // RenderJSON renders v in JSON format and sets status if provided.
@@ -248,10 +350,16 @@ func (ctx *Context) RenderPrettyXML(v interface{}, status ...int) error {
 
 // ResolveJSON resolve the request body into JSON format.
 func (ctx *Context) ResolveJSON(v interface{}) error {
-	return json.NewDecoder(ctx.Request.Body).Decode(v)
+	if err := ctx.prepareBody(); err != nil {
+		return err
+	}
+	return JSONDecoder.Decode(ctx.Request, v, ctx.strictBody)
 }
 
 // ResolveXML resolve the request body into XML format.
 func (ctx *Context) ResolveXML(v interface{}) error {
-	return xml.NewDecoder(ctx.Request.Body).Decode(v)
+	if err := ctx.prepareBody(); err != nil {
+		return err
+	}
+	return XMLDecoder.Decode(ctx.Request, v, ctx.strictBody)
 }
@@ -0,0 +1,245 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Decoder decodes body of req into v. Strict decoders should reject unknown fields.
+type Decoder interface {
+	Decode(req *http.Request, v interface{}, strict bool) error
+}
+
+// DecoderFunc is a function implements Decoder interface.
+type DecoderFunc func(req *http.Request, v interface{}, strict bool) error
+
+// Decode implements Decoder interface
+func (df DecoderFunc) Decode(req *http.Request, v interface{}, strict bool) error {
+	return df(req, v, strict)
+}
+
+// defaultMaxMemory is the max memory used by MultipartDecoder, same as http.Request.FormValue.
+const defaultMaxMemory = 32 << 20
+
+var (
+	// JSONDecoder decodes body in JSON format.
+	JSONDecoder = DecoderFunc(func(req *http.Request, v interface{}, strict bool) error {
+		dec := json.NewDecoder(req.Body)
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		return dec.Decode(v)
+	})
+	// XMLDecoder decodes body in XML format. encoding/xml can't reject unknown
+	// elements, so strict is ignored.
+	XMLDecoder = DecoderFunc(func(req *http.Request, v interface{}, strict bool) error {
+		return xml.NewDecoder(req.Body).Decode(v)
+	})
+	// FormDecoder parses body into req.PostForm, v is left to be bound by form tags.
+	FormDecoder = DecoderFunc(func(req *http.Request, v interface{}, strict bool) error {
+		return req.ParseForm()
+	})
+	// MultipartDecoder parses body into req.MultipartForm, v is left to be bound by
+	// form tags.
+	MultipartDecoder = DecoderFunc(func(req *http.Request, v interface{}, strict bool) error {
+		return req.ParseMultipartForm(defaultMaxMemory)
+	})
+)
+
+// Decoders is a registry of Decoder by media type of Content-Type header.
+type Decoders struct {
+	decoders map[string]Decoder
+}
+
+// NewDecoders creates new Decoders instance with JSON, XML, form and multipart
+// registered.
+func NewDecoders() *Decoders {
+	ds := &Decoders{decoders: make(map[string]Decoder)}
+	ds.Register("application/json", JSONDecoder)
+	ds.Register("application/xml", XMLDecoder)
+	ds.Register("text/xml", XMLDecoder)
+	ds.Register("application/x-www-form-urlencoded", FormDecoder)
+	ds.Register("multipart/form-data", MultipartDecoder)
+	return ds
+}
+
+var defaultDecoders = NewDecoders()
+
+// Register registers decoder for mediaType, e.g. "application/msgpack".
+func (ds *Decoders) Register(mediaType string, decoder Decoder) *Decoders {
+	ds.decoders[strings.ToLower(mediaType)] = decoder
+	return ds
+}
+
+// lookup returns the Decoder of mediaType. Structured syntax suffixes like
+// "application/problem+json" fall back to decoders of "application/json".
+func (ds *Decoders) lookup(mediaType string) Decoder {
+	if decoder, ok := ds.decoders[mediaType]; ok {
+		return decoder
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		return ds.decoders["application/"+mediaType[i+1:]]
+	}
+	return nil
+}
+
+// Decoders returns the Decoders used by Context.Decode. Decoders should be
+// registered before serving.
+func (h *Hador) Decoders() *Decoders {
+	return h.decoders
+}
+
+// BodyLimit returns a Filter limiting size of request bodies in max bytes, which
+// overrides Hador.MaxBodySize for routes or groups it filters. Requests declaring a
+// larger Content-Length are responded 413 at once.
+func BodyLimit(max int64) FilterFunc {
+	return func(ctx *Context, next Handler) {
+		ctx.maxBodySize = max
+		if max > 0 && ctx.Request.ContentLength > max {
+			ctx.OnError(http.StatusRequestEntityTooLarge)
+			return
+		}
+		next.Serve(ctx)
+	}
+}
+
+// StrictBody returns a Filter setting whether Context.Decode rejects unknown fields
+// for routes or groups it filters, which overrides Hador.StrictDecoding.
+func StrictBody(strict bool) FilterFunc {
+	return func(ctx *Context, next Handler) {
+		ctx.strictBody = strict
+		next.Serve(ctx)
+	}
+}
+
+// Decode decodes the request body into v by the Decoder registered for its
+// Content-Type, JSON if absent. gzip or deflate encoded bodies are decompressed.
+// Errors are *Error with status 413 if the body is too large, 415 if Content-Type
+// or Content-Encoding isn't supported, or 400 if it's malformed, which could be
+// responded by HandleError. io.EOF is returned as is for empty bodies.
+func (ctx *Context) Decode(v interface{}) error {
+	mediaType := "application/json"
+	if ct := ctx.Request.Header.Get("Content-Type"); ct != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(ct); err != nil {
+			return NewError(http.StatusUnsupportedMediaType, "invalid Content-Type").WithCause(err)
+		}
+	}
+	ds := ctx.decoders
+	if ds == nil {
+		ds = defaultDecoders
+	}
+	decoder := ds.lookup(mediaType)
+	if decoder == nil {
+		return NewError(http.StatusUnsupportedMediaType, "unsupported Content-Type "+mediaType)
+	}
+	if err := ctx.prepareBody(); err != nil {
+		return err
+	}
+	err := decoder.Decode(ctx.Request, v, ctx.strictBody)
+	if err == nil || err == io.EOF {
+		return err
+	}
+	return bodyError(err)
+}
+
+func bodyError(err error) error {
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return NewError(http.StatusRequestEntityTooLarge, "request body too large").WithCause(err)
+	}
+	return NewError(http.StatusBadRequest, err.Error()).WithCause(err)
+}
+
+// prepareBody wraps the request body with size limit and decompression, only once.
+// The error is kept and returned by later calls as well.
+func (ctx *Context) prepareBody() error {
+	req := ctx.Request
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if !ctx.bodyPrepared {
+		ctx.bodyPrepared = true
+		ctx.bodyErr = ctx.wrapBody()
+	}
+	return ctx.bodyErr
+}
+
+func (ctx *Context) wrapBody() error {
+	req := ctx.Request
+	if ctx.maxBodySize > 0 {
+		req.Body = http.MaxBytesReader(ctx.Response, req.Body, ctx.maxBodySize)
+		if req.ContentLength > ctx.maxBodySize {
+			return NewError(http.StatusRequestEntityTooLarge, "request body too large")
+		}
+	}
+	var body io.ReadCloser
+	switch encoding := strings.ToLower(req.Header.Get("Content-Encoding")); encoding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return bodyError(err)
+		}
+		body = zr
+	case "deflate":
+		zr, err := zlib.NewReader(req.Body)
+		if err != nil {
+			return bodyError(err)
+		}
+		body = zr
+	default:
+		return NewError(http.StatusUnsupportedMediaType, "unsupported Content-Encoding "+encoding)
+	}
+	if ctx.maxBodySize > 0 {
+		// limits decompressed size as well against compression bombs
+		body = http.MaxBytesReader(ctx.Response, body, ctx.maxBodySize)
+	}
+	req.Body = &decompressedBody{ReadCloser: body, raw: req.Body}
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+	return nil
+}
+
+type decompressedBody struct {
+	io.ReadCloser
+	raw io.Closer
+}
+
+func (b *decompressedBody) Close() error {
+	err := b.ReadCloser.Close()
+	if rerr := b.raw.Close(); err == nil {
+		err = rerr
+	}
+	return err
+}
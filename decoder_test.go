@@ -0,0 +1,158 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type decodedUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestDecode(t *testing.T) {
+	convey.Convey("Given Hador decoding bodies", t, func() {
+		h := New()
+		h.MaxBodySize = 64
+		h.Decoders().Register("text/plain", DecoderFunc(func(req *http.Request, v interface{}, strict bool) error {
+			data, err := io.ReadAll(req.Body)
+			if err != nil {
+				return err
+			}
+			v.(*decodedUser).Name = string(data)
+			return nil
+		}))
+		handler := func(ctx *Context) {
+			var u decodedUser
+			if err := ctx.Decode(&u); err != nil {
+				ctx.HandleError(err)
+				return
+			}
+			ctx.RenderJSON(u)
+		}
+		h.Post("/users", handler)
+		h.Post("/strict", handler, StrictBody(true))
+		h.Post("/large", handler, BodyLimit(1024))
+		h.Post("/small", handler, BodyLimit(8))
+		h.Post("/retry", func(ctx *Context) {
+			var u decodedUser
+			if err := ctx.Decode(&u); err == nil {
+				return
+			}
+			ctx.HandleError(ctx.Decode(&u))
+		})
+
+		serve := func(path, contentType, encoding string, body io.Reader) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", path, body)
+			if contentType != "" {
+				req.Header.Set("Content-Type", contentType)
+			}
+			if encoding != "" {
+				req.Header.Set("Content-Encoding", encoding)
+			}
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+		decoded := func(resp *httptest.ResponseRecorder) decodedUser {
+			var u decodedUser
+			json.Unmarshal(resp.Body.Bytes(), &u)
+			return u
+		}
+
+		convey.Convey("by Content-Type", func() {
+			resp := serve("/users", "", "", strings.NewReader(`{"name":"jack","age":18}`))
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(decoded(resp), convey.ShouldResemble, decodedUser{Name: "jack", Age: 18})
+
+			resp = serve("/users", "application/vnd.api+json", "", strings.NewReader(`{"name":"jack"}`))
+			convey.So(decoded(resp).Name, convey.ShouldEqual, "jack")
+
+			resp = serve("/users", "text/plain; charset=utf-8", "", strings.NewReader("rose"))
+			convey.So(decoded(resp).Name, convey.ShouldEqual, "rose")
+
+			resp = serve("/users", "image/png", "", strings.NewReader("png"))
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusUnsupportedMediaType)
+
+			resp = serve("/users", "", "", strings.NewReader(`{"name":`))
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusBadRequest)
+		})
+
+		convey.Convey("in strict mode", func() {
+			body := `{"name":"jack","admin":true}`
+			resp := serve("/users", "", "", strings.NewReader(body))
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusOK)
+			resp = serve("/strict", "", "", strings.NewReader(body))
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(resp.Body.String(), convey.ShouldContainSubstring, "unknown field")
+		})
+
+		convey.Convey("with size limits", func() {
+			body := `{"name":"` + strings.Repeat("a", 100) + `"}`
+			resp := serve("/users", "", "", strings.NewReader(body))
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusRequestEntityTooLarge)
+			resp = serve("/users", "", "", io.MultiReader(strings.NewReader(body)))
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusRequestEntityTooLarge)
+			resp = serve("/large", "", "", strings.NewReader(body))
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusOK)
+			resp = serve("/small", "", "", strings.NewReader(`{"name":"jack"}`))
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusRequestEntityTooLarge)
+			resp = serve("/retry", "", "", strings.NewReader(body))
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusRequestEntityTooLarge)
+		})
+
+		convey.Convey("compressed", func() {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			zw.Write([]byte(`{"name":"jack"}`))
+			zw.Close()
+			resp := serve("/users", "", "gzip", &buf)
+			convey.So(decoded(resp).Name, convey.ShouldEqual, "jack")
+
+			buf.Reset()
+			fw := zlib.NewWriter(&buf)
+			fw.Write([]byte(`{"name":"rose"}`))
+			fw.Close()
+			resp = serve("/users", "", "deflate", &buf)
+			convey.So(decoded(resp).Name, convey.ShouldEqual, "rose")
+
+			buf.Reset()
+			zw = gzip.NewWriter(&buf)
+			zw.Write([]byte(`{"name":"` + strings.Repeat("a", 1000) + `"}`))
+			zw.Close()
+			resp = serve("/users", "", "gzip", &buf)
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusRequestEntityTooLarge)
+
+			resp = serve("/users", "", "br", strings.NewReader("xx"))
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusUnsupportedMediaType)
+			resp = serve("/users", "", "gzip", strings.NewReader("not gzip"))
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
@@ -17,7 +17,13 @@
 
 package hador
 
-import "net/http"
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
 
 // HTTPError HTTP error type
 type HTTPError int
@@ -29,4 +35,161 @@ func (e HTTPError) Error() string {
 const (
 	err404 HTTPError = http.StatusNotFound
 	err405 HTTPError = http.StatusMethodNotAllowed
+	err406 HTTPError = http.StatusNotAcceptable
 )
+
+// Error is an HTTP error carrying the response of it. Handlers could return it, or
+// panic with it under NewRecoveryFilter, and it's rendered as RFC 7807 problem+json
+// by OnError if no error handler is registered.
+type Error struct {
+	// Status is the status code of response, 500 if zero.
+	Status int
+	// Type is a URI identifying the problem type, "about:blank" if empty.
+	Type string
+	// Code is the application specific error code.
+	Code    string
+	Message string
+	Details interface{}
+	Headers http.Header
+	// Cause is the underlying error, which is never exposed to clients.
+	Cause error
+}
+
+// NewError creates new Error instance
+func NewError(status int, message string) *Error {
+	return &Error{Status: status, Message: message}
+}
+
+func (e *Error) status() int {
+	if e.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return e.Status
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.status())
+	}
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+// Unwrap returns Cause of Error
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// WithCode sets application specific error code
+func (e *Error) WithCode(code string) *Error {
+	e.Code = code
+	return e
+}
+
+// WithDetails sets details of Error
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+// WithHeader adds response header
+func (e *Error) WithHeader(key, value string) *Error {
+	if e.Headers == nil {
+		e.Headers = make(http.Header)
+	}
+	e.Headers.Add(key, value)
+	return e
+}
+
+// WithCause sets the underlying error
+func (e *Error) WithCause(cause error) *Error {
+	e.Cause = cause
+	return e
+}
+
+// Problem is the RFC 7807 problem details of Error.
+type Problem struct {
+	XMLName  xml.Name    `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	Type     string      `json:"type" xml:"type"`
+	Title    string      `json:"title" xml:"title"`
+	Status   int         `json:"status" xml:"status"`
+	Detail   string      `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance string      `json:"instance,omitempty" xml:"instance,omitempty"`
+	Code     string      `json:"code,omitempty" xml:"code,omitempty"`
+	Details  interface{} `json:"details,omitempty" xml:"-"`
+}
+
+// Problem returns problem details of Error responded with status, instance is
+// the URI of the request. Status of Error is used if status is zero.
+func (e *Error) Problem(status int, instance string) *Problem {
+	if status == 0 {
+		status = e.status()
+	}
+	p := &Problem{
+		Type:     e.Type,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   e.Message,
+		Instance: instance,
+		Code:     e.Code,
+		Details:  e.Details,
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	return p
+}
+
+// errorStatus returns status of err if it's an Error or HTTPError.
+func errorStatus(err error) (int, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.status(), true
+	}
+	var he HTTPError
+	if errors.As(err, &he) {
+		return int(he), true
+	}
+	return 0, false
+}
+
+// RouteError describes a route which can't be registered.
+type RouteError struct {
+	Method  Method
+	Pattern string
+	// Conflict is the existing route clashed with, empty if none.
+	Conflict string
+	Reason   string
+}
+
+func (e *RouteError) Error() string {
+	msg := e.Reason
+	if e.Pattern != "" {
+		msg = fmt.Sprintf("%s %s: %s", e.Method, e.Pattern, msg)
+	}
+	if e.Conflict != "" {
+		msg += ", conflicts with " + e.Conflict
+	}
+	return msg
+}
+
+// RouteErrors is the registration report, a list of RouteError.
+type RouteErrors []*RouteError
+
+func (es RouteErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (es RouteErrors) err() error {
+	if len(es) == 0 {
+		return nil
+	}
+	return es
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"html/template"
+	"net/http"
+	"reflect"
+)
+
+// ErrorHandlerFunc handles errors responded by Context.OnError. err is the error
+// passed to OnError, or HTTPError of status if there isn't one.
+type ErrorHandlerFunc func(ctx *Context, status int, err error)
+
+type errorTypeHandler struct {
+	typ     reflect.Type
+	handler ErrorHandlerFunc
+}
+
+// ErrorHandlers is a registry of ErrorHandlerFunc, resolved by error type, status,
+// class of status (4XX or 5XX) and fallback in order. It's a Filter as well, which
+// makes itself available to requests it filters, so that groups could have their
+// own ones, tried before those of Hador.
+type ErrorHandlers struct {
+	types    []errorTypeHandler
+	statuses map[int]ErrorHandlerFunc
+	classes  map[int]ErrorHandlerFunc
+	fallback ErrorHandlerFunc
+}
+
+// NewErrorHandlers creates new ErrorHandlers instance
+func NewErrorHandlers() *ErrorHandlers {
+	return &ErrorHandlers{
+		statuses: make(map[int]ErrorHandlerFunc),
+		classes:  make(map[int]ErrorHandlerFunc),
+	}
+}
+
+// Type registers handler for errors of the same type as target, matched by errors.As.
+// target could be a value, e.g. (*MyError)(nil), or pointer to an interface,
+// e.g. (*net.Error)(nil).
+func (eh *ErrorHandlers) Type(target interface{}, handler ErrorHandlerFunc) *ErrorHandlers {
+	t := reflect.TypeOf(target)
+	if t == nil {
+		panic("target shouldn't be nil")
+	}
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Interface {
+		t = t.Elem()
+	}
+	if !t.Implements(errorType) {
+		panic(t.String() + " doesn't implement error")
+	}
+	eh.types = append(eh.types, errorTypeHandler{typ: t, handler: handler})
+	return eh
+}
+
+// Status registers handler for status
+func (eh *ErrorHandlers) Status(status int, handler ErrorHandlerFunc) *ErrorHandlers {
+	eh.statuses[status] = handler
+	return eh
+}
+
+// Status4XX registers handler for all 4XX statuses
+func (eh *ErrorHandlers) Status4XX(handler ErrorHandlerFunc) *ErrorHandlers {
+	eh.classes[4] = handler
+	return eh
+}
+
+// Status5XX registers handler for all 5XX statuses
+func (eh *ErrorHandlers) Status5XX(handler ErrorHandlerFunc) *ErrorHandlers {
+	eh.classes[5] = handler
+	return eh
+}
+
+// Fallback registers handler for all errors unmatched.
+func (eh *ErrorHandlers) Fallback(handler ErrorHandlerFunc) *ErrorHandlers {
+	eh.fallback = handler
+	return eh
+}
+
+// Filter implements Filter interface
+func (eh *ErrorHandlers) Filter(ctx *Context, next Handler) {
+	ctx.errorHandlers = append(ctx.errorHandlers, eh)
+	next.Serve(ctx)
+}
+
+func (eh *ErrorHandlers) resolve(status int, err error) ErrorHandlerFunc {
+	for _, th := range eh.types {
+		if errors.As(err, reflect.New(th.typ).Interface()) {
+			return th.handler
+		}
+	}
+	if handler, ok := eh.statuses[status]; ok {
+		return handler
+	}
+	if handler, ok := eh.classes[status/100]; ok {
+		return handler
+	}
+	return eh.fallback
+}
+
+// ErrorHandlers returns the ErrorHandlers of Hador, which handles errors of all
+// requests, including 404 and 405 responded by router.
+func (h *Hador) ErrorHandlers() *ErrorHandlers {
+	if h.errorHandlers == nil {
+		h.errorHandlers = NewErrorHandlers()
+	}
+	return h.errorHandlers
+}
+
+// resolveErrorHandler returns the handler for status and err from the innermost
+// ErrorHandlers, nil if there isn't one.
+func (ctx *Context) resolveErrorHandler(status int, err error) ErrorHandlerFunc {
+	for i := len(ctx.errorHandlers) - 1; i >= 0; i-- {
+		if handler := ctx.errorHandlers[i].resolve(status, err); handler != nil {
+			return handler
+		}
+	}
+	return nil
+}
+
+// findAnyError returns the first error in args, HTTPError of status if there isn't one.
+func findAnyError(args []interface{}, status int) error {
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			return err
+		}
+	}
+	return HTTPError(status)
+}
+
+const (
+	contentTypeProblemXML = "application/problem+xml"
+	contentTypeHTML       = "text/html; charset=utf-8"
+)
+
+var errorPageTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Status}} {{.Title}}</title></head>
+<body>
+<h1>{{.Status}} {{.Title}}</h1>
+{{if .Detail}}<p>{{.Detail}}</p>
+{{end}}</body>
+</html>
+`))
+
+// RenderError is an ErrorHandlerFunc rendering problem details of err as JSON, XML
+// or HTML negotiated by Accept header. Messages of errors other than Error are
+// rendered only for 4XX statuses.
+func RenderError(ctx *Context, status int, err error) {
+	var p *Problem
+	var e *Error
+	if errors.As(err, &e) {
+		p = e.Problem(status, ctx.Request.URL.RequestURI())
+	} else {
+		p = (&Error{}).Problem(status, ctx.Request.URL.RequestURI())
+		if _, ok := err.(HTTPError); !ok && status < 500 {
+			p.Detail = err.Error()
+		}
+	}
+
+	var data []byte
+	var contentType string
+	var merr error
+	switch negotiate(ctx.Request.Header.Get("Accept"),
+		contentTypeProblemJSON, "application/json",
+		contentTypeProblemXML, "application/xml", "text/xml",
+		"text/html") {
+	case contentTypeProblemXML, "application/xml", "text/xml":
+		contentType = contentTypeProblemXML
+		data, merr = xml.Marshal(p)
+	case "text/html":
+		contentType = contentTypeHTML
+		buf := new(bytes.Buffer)
+		merr = errorPageTemplate.Execute(buf, p)
+		data = buf.Bytes()
+	default:
+		contentType = contentTypeProblemJSON
+		data, merr = json.Marshal(p)
+	}
+	if merr != nil {
+		ctx.Logger.Error("failed to render error: %s", merr)
+		http.Error(ctx.Response, http.StatusText(status), status)
+		return
+	}
+	ctx.SetHeader("Content-Type", contentType)
+	ctx.SetHeader("X-Content-Type-Options", "nosniff")
+	ctx.WriteStatus(data, status)
+}
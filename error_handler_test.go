@@ -0,0 +1,155 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type notFoundError struct{ id string }
+
+func (e *notFoundError) Error() string { return e.id + " not found" }
+
+func TestErrorHandlers(t *testing.T) {
+	convey.Convey("Given Hador with ErrorHandlers", t, func() {
+		h := New()
+		h.ErrorHandlers().
+			Status(http.StatusNotFound, func(ctx *Context, status int, err error) {
+				ctx.WriteString("app 404", status)
+			}).
+			Status5XX(RenderError).
+			Fallback(func(ctx *Context, status int, err error) {
+				ctx.WriteString("fallback: "+err.Error(), status)
+			})
+		h.Get("/teapot", func(ctx *Context) {
+			ctx.OnError(http.StatusTeapot)
+		})
+		h.Get("/internal", func(ctx *Context) {
+			ctx.OnError(http.StatusInternalServerError, errors.New("secret"))
+		})
+		h.Group("/api", func(r Router) {
+			r.Get("/users/{id}", func(ctx *Context) error {
+				return &notFoundError{id: ctx.Params().GetStringMust("id", "")}
+			})
+			r.Get("/bad", func(ctx *Context) {
+				ctx.OnError(http.StatusBadRequest, errors.New("bad input"))
+			})
+			r.Get("/missing", func(ctx *Context) {
+				ctx.OnError(http.StatusNotFound)
+			})
+		}, NewErrorHandlers().
+			Type((*notFoundError)(nil), func(ctx *Context, status int, err error) {
+				ctx.WriteString("group: "+err.Error(), http.StatusNotFound)
+			}).
+			Status4XX(RenderError))
+
+		serve := func(path, accept string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			if accept != "" {
+				req.Header.Set("Accept", accept)
+			}
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+
+		convey.Convey("router errors use handlers of Hador", func() {
+			resp := serve("/nowhere", "")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusNotFound)
+			convey.So(resp.Body.String(), convey.ShouldEqual, "app 404")
+		})
+
+		convey.Convey("fallback handles unmatched errors", func() {
+			resp := serve("/teapot", "")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusTeapot)
+			convey.So(resp.Body.String(), convey.ShouldEqual, "fallback: I'm a teapot")
+		})
+
+		convey.Convey("5XX errors are rendered without details", func() {
+			resp := serve("/internal", "")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusInternalServerError)
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldEqual, contentTypeProblemJSON)
+			convey.So(resp.Body.String(), convey.ShouldNotContainSubstring, "secret")
+		})
+
+		convey.Convey("handlers of groups are tried first", func() {
+			resp := serve("/api/users/42", "")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusNotFound)
+			convey.So(resp.Body.String(), convey.ShouldEqual, "group: 42 not found")
+
+			resp = serve("/api/missing", "")
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldEqual, contentTypeProblemJSON)
+
+			resp = serve("/api/bad", "")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusBadRequest)
+			var p Problem
+			convey.So(json.Unmarshal(resp.Body.Bytes(), &p), convey.ShouldBeNil)
+			convey.So(p.Status, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(p.Detail, convey.ShouldEqual, "bad input")
+			convey.So(p.Instance, convey.ShouldEqual, "/api/bad")
+		})
+
+		convey.Convey("RenderError negotiates by Accept", func() {
+			resp := serve("/api/bad", "application/xml")
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldEqual, contentTypeProblemXML)
+			convey.So(resp.Body.String(), convey.ShouldContainSubstring, "<detail>bad input</detail>")
+
+			resp = serve("/api/bad", "text/html,*/*;q=0.8")
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldEqual, contentTypeHTML)
+			convey.So(resp.Body.String(), convey.ShouldContainSubstring, "<h1>400 Bad Request</h1>")
+		})
+
+		convey.Convey("per request handlers take precedence", func() {
+			h.Get("/custom", func(ctx *Context) {
+				ctx.SetErrorHandler(http.StatusNotFound, func(args ...interface{}) {
+					ctx.WriteString("custom", http.StatusNotFound)
+				})
+				ctx.OnError(http.StatusNotFound)
+			})
+			resp := serve("/custom", "")
+			convey.So(resp.Body.String(), convey.ShouldEqual, "custom")
+		})
+
+		convey.Convey("Type panics on non-error types", func() {
+			convey.So(func() { NewErrorHandlers().Type("", nil) }, convey.ShouldPanic)
+			convey.So(func() { NewErrorHandlers().Type((*error)(nil), nil) }, convey.ShouldNotPanic)
+			convey.So(func() { NewErrorHandlers().Type((*os.PathError)(nil), nil) }, convey.ShouldNotPanic)
+		})
+	})
+}
+
+func TestNegotiate(t *testing.T) {
+	convey.Convey("Given offers", t, func() {
+		offers := []string{"application/json", "application/xml", "text/html"}
+		convey.So(negotiate("", offers...), convey.ShouldEqual, "application/json")
+		convey.So(negotiate("*/*", offers...), convey.ShouldEqual, "application/json")
+		convey.So(negotiate("text/*", offers...), convey.ShouldEqual, "text/html")
+		convey.So(negotiate("application/xml;q=0.9, application/json;q=0.5", offers...), convey.ShouldEqual, "application/xml")
+		convey.So(negotiate("*/*;q=0.1, application/json;q=0", offers...), convey.ShouldEqual, "application/xml")
+		convey.So(negotiate("image/png", offers...), convey.ShouldEqual, "")
+		convey.So(strings.Join(offers, ","), convey.ShouldEqual, "application/json,application/xml,text/html")
+	})
+}
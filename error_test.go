@@ -0,0 +1,114 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Xuyuanp/logo"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestError(t *testing.T) {
+	convey.Convey("Given an Error", t, func() {
+		cause := io.ErrUnexpectedEOF
+		e := NewError(http.StatusConflict, "user exists").
+			WithCode("USER_EXISTS").
+			WithDetails(map[string]string{"name": "jack"}).
+			WithHeader("Retry-After", "10").
+			WithCause(cause)
+		convey.So(e.Error(), convey.ShouldEqual, "user exists: unexpected EOF")
+		convey.So(errors.Is(e, cause), convey.ShouldBeTrue)
+		convey.So(NewError(http.StatusTeapot, "").Error(), convey.ShouldEqual, "I'm a teapot")
+
+		h := New()
+		var writer bytes.Buffer
+		h.Before(NewRecoveryFilter(logo.New(logo.LevelDebug, &writer, "", 0)))
+		h.Get("/return", func(ctx *Context) error {
+			return e
+		})
+		h.Get("/panic", func(ctx *Context) {
+			panic(NewError(http.StatusPaymentRequired, "pay first"))
+		})
+		h.Get("/zero", func(ctx *Context) error {
+			return &Error{Message: "boom"}
+		})
+		h.Get("/internal", func(ctx *Context) error {
+			return errors.New("secret")
+		})
+		h.Get("/custom", func(ctx *Context) {
+			ctx.SetErrorHandler(http.StatusConflict, func(args ...interface{}) {
+				ctx.WriteString("custom", http.StatusConflict)
+			})
+			ctx.HandleError(e)
+		})
+
+		serve := func(path string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+
+		convey.Convey("rendered as problem+json", func() {
+			resp := serve("/return?a=1")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusConflict)
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldEqual, "application/problem+json")
+			convey.So(resp.Header().Get("Retry-After"), convey.ShouldEqual, "10")
+			var p map[string]interface{}
+			convey.So(json.Unmarshal(resp.Body.Bytes(), &p), convey.ShouldBeNil)
+			convey.So(p, convey.ShouldResemble, map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Conflict",
+				"status":   float64(http.StatusConflict),
+				"detail":   "user exists",
+				"instance": "/return?a=1",
+				"code":     "USER_EXISTS",
+				"details":  map[string]interface{}{"name": "jack"},
+			})
+		})
+		convey.Convey("panicked", func() {
+			resp := serve("/panic")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusPaymentRequired)
+			convey.So(resp.Body.String(), convey.ShouldContainSubstring, "pay first")
+			convey.So(writer.String(), convey.ShouldNotContainSubstring, "PANIC")
+		})
+		convey.Convey("internal errors are hidden", func() {
+			resp := serve("/internal")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusInternalServerError)
+			convey.So(resp.Body.String(), convey.ShouldNotContainSubstring, "secret")
+		})
+		convey.Convey("zero status", func() {
+			resp := serve("/zero")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusInternalServerError)
+			convey.So(resp.Body.String(), convey.ShouldContainSubstring, `"status":500`)
+			convey.So(resp.Body.String(), convey.ShouldContainSubstring, "boom")
+		})
+		convey.Convey("custom error handler", func() {
+			resp := serve("/custom")
+			convey.So(resp.Body.String(), convey.ShouldEqual, "custom")
+			convey.So(resp.Header().Get("Retry-After"), convey.ShouldEqual, "10")
+		})
+	})
+}
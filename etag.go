@@ -0,0 +1,285 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag is a Filter setting ETag of GET and HEAD responses by hashing their bodies,
+// unless set by handlers, and answering conditional requests with 304 or 412.
+type ETag struct {
+	// Weak generates weak ETags.
+	Weak bool
+	// MaxSize is the max size of bodies held to hash, larger or flushed ones are
+	// sent as is.
+	MaxSize int
+}
+
+// NewETag creates new ETag instance with defaults.
+func NewETag() *ETag {
+	return &ETag{MaxSize: 1 << 20}
+}
+
+// Filter implements Filter interface
+func (e *ETag) Filter(ctx *Context, next Handler) {
+	if ctx.Request.Method != "GET" && ctx.Request.Method != "HEAD" {
+		next.Serve(ctx)
+		return
+	}
+	ew := &etagWriter{ResponseWriter: ctx.Response, e: e}
+	ctx.Response = ew
+	next.Serve(ctx)
+	ctx.Response = ew.ResponseWriter
+	if ew.status != http.StatusOK || ew.passed {
+		return
+	}
+	header := ew.Header()
+	if header.Get("ETag") == "" {
+		sum := sha256.Sum256(ew.buf.Bytes())
+		header.Set("ETag", formatETag(hex.EncodeToString(sum[:16]), e.Weak))
+	}
+	if ctx.checkPreconditions() {
+		return
+	}
+	ew.ResponseWriter.WriteHeader(http.StatusOK)
+	ew.ResponseWriter.Write(ew.buf.Bytes())
+}
+
+// etagWriter holds successful responses to hash.
+type etagWriter struct {
+	ResponseWriter
+	e *ETag
+
+	status int
+	size   int
+	buf    bytes.Buffer
+	passed bool
+}
+
+func (ew *etagWriter) WriteHeader(status int) {
+	if ew.status != 0 {
+		return
+	}
+	ew.status = status
+	if status != http.StatusOK {
+		ew.pass()
+	}
+}
+
+func (ew *etagWriter) Write(p []byte) (int, error) {
+	if ew.status == 0 {
+		ew.WriteHeader(http.StatusOK)
+	}
+	ew.size += len(p)
+	if ew.passed {
+		return ew.ResponseWriter.Write(p)
+	}
+	ew.buf.Write(p)
+	if ew.buf.Len() > ew.e.MaxSize {
+		if err := ew.pass(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (ew *etagWriter) WriteString(s string) (int, error) {
+	return ew.Write([]byte(s))
+}
+
+func (ew *etagWriter) Status() int {
+	return ew.status
+}
+
+func (ew *etagWriter) Written() bool {
+	return ew.status != 0
+}
+
+func (ew *etagWriter) Size() int {
+	return ew.size
+}
+
+func (ew *etagWriter) Flush() {
+	if ew.status == 0 {
+		return
+	}
+	ew.pass()
+	ew.ResponseWriter.Flush()
+}
+
+func (ew *etagWriter) hijacked(status int) {
+	ew.status = status
+	ew.passed = true
+	recordHijacked(ew.ResponseWriter, status)
+}
+
+func (ew *etagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := ew.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	return hijacker.Hijack()
+}
+
+// pass sends what's held, and the rest as is.
+func (ew *etagWriter) pass() error {
+	if ew.passed {
+		return nil
+	}
+	ew.passed = true
+	ew.ResponseWriter.WriteHeader(ew.status)
+	if ew.buf.Len() == 0 {
+		return nil
+	}
+	_, err := ew.ResponseWriter.Write(ew.buf.Bytes())
+	ew.buf.Reset()
+	return err
+}
+
+func formatETag(version string, weak bool) string {
+	if weak {
+		return `W/"` + version + `"`
+	}
+	return `"` + version + `"`
+}
+
+// SetETag sets ETag of the response to version, which shouldn't contain '"', and
+// checks conditional headers of the request against it and Last-Modified at the
+// first call. It returns true if the request has been answered with 304 or 412,
+// where the handler should stop, e.g. before updating a resource changed since the
+// client read it.
+func (ctx *Context) SetETag(version string, weak bool) bool {
+	ctx.SetHeader("ETag", formatETag(version, weak))
+	return ctx.checkPreconditions()
+}
+
+// SetLastModified sets Last-Modified of the response, which is checked by
+// SetETag, or ETag filter if SetETag isn't called.
+func (ctx *Context) SetLastModified(t time.Time) {
+	ctx.SetHeader("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+// checkPreconditions answers the request with 304 or 412 if its conditional
+// headers fail, and returns true if answered. They're checked only once, so that
+// the ETag of the updated resource could be set after passed.
+func (ctx *Context) checkPreconditions() bool {
+	if ctx.preconditionsChecked {
+		return false
+	}
+	ctx.preconditionsChecked = true
+	switch checkPreconditions(ctx.Request, ctx.Response.Header()) {
+	case http.StatusNotModified:
+		header := ctx.Response.Header()
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		ctx.WriteHeader(http.StatusNotModified)
+		return true
+	case http.StatusPreconditionFailed:
+		ctx.OnError(http.StatusPreconditionFailed)
+		return true
+	}
+	return false
+}
+
+// checkPreconditions evaluates conditional headers of req against ETag and
+// Last-Modified in header in the order of RFC 7232 section 6. It returns 304, 412,
+// or 0 if the request should proceed.
+func checkPreconditions(req *http.Request, header http.Header) int {
+	etag := header.Get("ETag")
+	lastModified, _ := http.ParseTime(header.Get("Last-Modified"))
+	if ifMatch := req.Header.Get("If-Match"); ifMatch != "" {
+		if !matchETags(ifMatch, etag, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(req.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+	safe := req.Method == "GET" || req.Method == "HEAD"
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if matchETags(ifNoneMatch, etag, false) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil && safe && !lastModified.IsZero() {
+		if !lastModified.After(since) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// matchETags returns if etag matches any in list by strong or weak comparison.
+func matchETags(list, etag string, strong bool) bool {
+	list = strings.TrimSpace(list)
+	if list == "*" {
+		return true
+	}
+	if etag == "" {
+		return false
+	}
+	for {
+		list = strings.TrimLeft(list, " \t,")
+		if list == "" {
+			return false
+		}
+		tag, rest := scanETag(list)
+		if tag == "" {
+			return false
+		}
+		if strong && !strings.HasPrefix(tag, "W/") && tag == etag ||
+			!strong && strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+		list = rest
+	}
+}
+
+// scanETag returns the first entity tag in s and the rest, or empty tag if s
+// doesn't start with a valid one.
+func scanETag(s string) (etag, rest string) {
+	start := 0
+	if strings.HasPrefix(s, "W/") {
+		start = 2
+	}
+	if len(s) <= start || s[start] != '"' {
+		return "", ""
+	}
+	for i := start + 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			return s[:i+1], s[i+1:]
+		case c == 0x21 || c >= 0x23 && c != 0x7f:
+		default:
+			return "", ""
+		}
+	}
+	return "", ""
+}
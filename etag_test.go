@@ -0,0 +1,134 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestETag(t *testing.T) {
+	convey.Convey("Given Hador with ETag", t, func() {
+		h := New()
+		h.Before(NewETag())
+		modified := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+		version := "v1"
+		h.Get("/hashed", func(ctx *Context) {
+			ctx.SetLastModified(modified)
+			ctx.SetHeader("Content-Type", "text/plain")
+			ctx.WriteString("hello")
+		})
+		h.Get("/large", func(ctx *Context) {
+			ctx.WriteString(strings.Repeat("a", 2<<20))
+		})
+		h.Get("/missing", func(ctx *Context) {
+			ctx.OnError(http.StatusNotFound)
+		})
+		h.Put("/doc", func(ctx *Context) {
+			if ctx.SetETag(version, false) {
+				return
+			}
+			version = "v2"
+			ctx.SetETag(version, false)
+			ctx.WriteString("updated")
+		})
+		serve := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, path, nil)
+			for key, values := range header {
+				req.Header[key] = values
+			}
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+
+		convey.Convey("ETags are generated", func() {
+			resp := serve("GET", "/hashed", nil)
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(resp.Body.String(), convey.ShouldEqual, "hello")
+			etag := resp.Header().Get("ETag")
+			convey.So(etag, convey.ShouldStartWith, `"`)
+			convey.So(etag, convey.ShouldHaveLength, 34)
+
+			resp = serve("GET", "/hashed", http.Header{"If-None-Match": {`"other", W/` + etag}})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusNotModified)
+			convey.So(resp.Body.Len(), convey.ShouldEqual, 0)
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldBeEmpty)
+			convey.So(resp.Header().Get("ETag"), convey.ShouldEqual, etag)
+
+			resp = serve("GET", "/hashed", http.Header{"If-None-Match": {`"other"`}})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusOK)
+
+			resp = serve("GET", "/hashed", http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusNotModified)
+
+			resp = serve("GET", "/hashed", http.Header{"If-Match": {`"other"`}})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusPreconditionFailed)
+
+			resp = serve("GET", "/hashed", http.Header{"If-Unmodified-Since": {modified.Add(-time.Hour).Format(http.TimeFormat)}})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusPreconditionFailed)
+		})
+
+		convey.Convey("large and failed responses are sent as is", func() {
+			resp := serve("GET", "/large", nil)
+			convey.So(resp.Header().Get("ETag"), convey.ShouldBeEmpty)
+			convey.So(resp.Body.Len(), convey.ShouldEqual, 2<<20)
+			resp = serve("GET", "/missing", http.Header{"If-None-Match": {"*"}})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusNotFound)
+			convey.So(resp.Header().Get("ETag"), convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("handlers check preconditions by SetETag", func() {
+			resp := serve("PUT", "/doc", http.Header{"If-Match": {`"v0"`}})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusPreconditionFailed)
+			convey.So(version, convey.ShouldEqual, "v1")
+
+			resp = serve("PUT", "/doc", http.Header{"If-Match": {`W/"v1"`}})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusPreconditionFailed)
+
+			resp = serve("PUT", "/doc", http.Header{"If-Match": {`"v0", "v1"`}})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(resp.Header().Get("ETag"), convey.ShouldEqual, `"v2"`)
+			convey.So(version, convey.ShouldEqual, "v2")
+
+			resp = serve("PUT", "/doc", http.Header{"If-None-Match": {"*"}})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusPreconditionFailed)
+		})
+
+		convey.Convey("compressed responses have weak ETags", func() {
+			c := New()
+			c.Before(NewCompression())
+			c.Before(NewETag())
+			c.Get("/", func(ctx *Context) {
+				ctx.SetHeader("Content-Type", "text/plain")
+				ctx.WriteString(strings.Repeat("hador ", 500))
+			})
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			c.ServeHTTP(resp, req)
+			convey.So(resp.Header().Get("Content-Encoding"), convey.ShouldEqual, "gzip")
+			convey.So(resp.Header().Get("ETag"), convey.ShouldStartWith, `W/"`)
+		})
+	})
+}
@@ -94,7 +94,7 @@ func main() {
 					DocResponseSimple("404", "not found")
 
 			}, UIDFilter())
-		}, errorFilter())
+		}, hador.NewErrorHandlers().Status4XX(hador.RenderError))
 	})
 
 	// swagger support
@@ -133,18 +133,6 @@ func UIDFilter() hador.FilterFunc {
 	}
 }
 
-// errorFilter handle error
-func errorFilter() hador.FilterFunc {
-	return func(ctx *hador.Context, next hador.Handler) {
-		// set error message as json format
-		ctx.Err4XXHandler = func(status int, args ...interface{}) {
-			text := http.StatusText(status)
-			ctx.RenderJSON(text, status)
-		}
-		next.Serve(ctx)
-	}
-}
-
 func getUserList(ctx *hador.Context) {
 	if len(fakeStore) == 0 {
 		ctx.OnError(http.StatusNotFound)
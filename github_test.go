@@ -281,12 +281,9 @@ var githubAPI = []route{
 	{"DELETE", "/user/keys/:id"},
 }
 
-func TestGithub(t *testing.T) {
+func newGithubHador(handler func(ctx *Context)) *Hador {
 	re := regexp.MustCompile(":([^/]*)")
 	h := New()
-	handler := func(ctx *Context) {
-		ctx.WriteString(ctx.Request.URL.Path)
-	}
 	for _, route := range githubAPI {
 		path := re.ReplaceAllStringFunc(route.path, func(str string) string {
 			return fmt.Sprintf("{%s}", str[1:])
@@ -294,6 +291,13 @@ func TestGithub(t *testing.T) {
 
 		h.AddRoute(Method(route.method), path, handler)
 	}
+	return h
+}
+
+func TestGithub(t *testing.T) {
+	h := newGithubHador(func(ctx *Context) {
+		ctx.WriteString(ctx.Request.URL.Path)
+	})
 
 	for _, route := range githubAPI {
 		req, _ := http.NewRequest(route.method, route.path, nil)
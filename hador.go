@@ -19,11 +19,11 @@ package hador
 
 import (
 	"container/list"
-	"fmt"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/Xuyuanp/hador/swagger"
 )
@@ -33,24 +33,55 @@ type Hador struct {
 	Router
 	*FilterChain
 	Logger Logger
-	root   *node
+	// UseRawPath routes requests on URL.EscapedPath instead of URL.Path, so that an
+	// encoded slash in "/files/a%2Fb" doesn't separate segments. Only encoded slashes
+	// and percents are kept escaped for routing, so static routes like "/café" still
+	// match. Params are unescaped individually, with the escaped form kept in Param.Raw.
+	UseRawPath bool
+	// MaxBodySize limits size of request bodies decoded by Context, unlimited if 0.
+	// BodyLimit overrides it for routes or groups.
+	MaxBodySize int64
+	// StrictDecoding makes Context.Decode reject unknown fields, overridden by
+	// StrictBody for routes or groups.
+	StrictDecoding bool
+
+	table atomic.Value // *routeTable
+	// tableMu serializes copy-on-write updates of table.
+	tableMu sync.Mutex
 
 	ctxPool  sync.Pool
 	respPool sync.Pool
 
 	document *swagger.Document
+
+	routeErrors RouteErrors
+
+	errorHandlers *ErrorHandlers
+	renderers     *Renderers
+	decoders      *Decoders
+	templates     *Templates
 }
 
 // New creates new Hador instance
 func New() *Hador {
-	h := &Hador{Logger: defaultLogger}
-	h.root = &node{}
-	h.Router = h.root.router()
+	h := &Hador{Logger: defaultLogger, renderers: NewRenderers(), decoders: NewDecoders()}
+	h.table.Store(newRouteTable())
+	h.Router = RouterFunc(func(method Method, pattern string, handler interface{}, filters ...Filter) *Leaf {
+		h.tableMu.Lock()
+		defer h.tableMu.Unlock()
+		leaf, err := h.routes().tryAddRoute(method, pattern, handler, filters...)
+		if err != nil {
+			panic(err)
+		}
+		return leaf
+	})
 	h.FilterChain = NewFilterChain(h)
 
 	h.ctxPool.New = func() interface{} {
 		ctx := newContext(h.Logger)
-		ctx.params = make(Params, h.root.findMaxParams())
+		ctx.renderers = h.renderers
+		ctx.decoders = h.decoders
+		ctx.params = make(Params, h.routes().maxParams)
 		return ctx
 	}
 	h.respPool.New = func() interface{} {
@@ -104,21 +135,65 @@ func (h *Hador) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	ctx := h.ctxPool.Get().(*Context)
 	ctx.reset(resp, req)
+	ctx.maxBodySize = h.MaxBodySize
+	ctx.strictBody = h.StrictDecoding
+	ctx.templates = h.templates
+	if h.errorHandlers != nil {
+		ctx.errorHandlers = append(ctx.errorHandlers, h.errorHandlers)
+	}
 
+	// cleaned up even if the handler panics without RecoveryFilter.
+	defer func() {
+		if ctx.sse != nil {
+			ctx.sse.Close()
+		}
+		if len(ctx.tempFiles) > 0 {
+			ctx.removeTempFiles()
+		}
+		h.ctxPool.Put(ctx)
+		h.respPool.Put(resp)
+	}()
 	h.FilterChain.Serve(ctx)
-
-	h.ctxPool.Put(ctx)
-	h.respPool.Put(resp)
 }
 
 // Serve implements Handler interface
 func (h *Hador) Serve(ctx *Context) {
 	method := Method(ctx.Request.Method)
 	path := ctx.Request.URL.Path
+	if h.UseRawPath {
+		path = routingPath(ctx.Request.URL.EscapedPath())
+	}
 	if len(path) > 1 && path[len(path)-1] == '/' {
 		path = path[:len(path)-1]
 	}
-	params, leaf, err := h.root.match(method, path, ctx.Params())
+	t := h.routes()
+	// the table may be swapped with routes having more params after ctx created.
+	if cap(ctx.params) < t.maxParams {
+		ctx.params = make(Params, 0, t.maxParams)
+	}
+	hr, params := t.matchHost(ctx.Request, ctx.Params())
+	var hostErr error
+	if hr != nil {
+		ps, leaf, err := hr.root.matchRequest(ctx.Request, method, path, params)
+		if err == nil {
+			h.serveLeaf(ctx, ps, leaf, nil)
+			return
+		}
+		// routes without host may still match the method.
+		hostErr = err
+	}
+	if leaf := t.matchStatic(ctx.Request, method, path); leaf != nil {
+		h.serveLeaf(ctx, params, leaf, nil)
+		return
+	}
+	params, leaf, err := t.root.matchRequest(ctx.Request, method, path, params)
+	if err == err404 && hostErr == err405 {
+		err = err405
+	}
+	h.serveLeaf(ctx, params, leaf, err)
+}
+
+func (h *Hador) serveLeaf(ctx *Context, params Params, leaf *Leaf, err error) {
 	if err != nil {
 		status := http.StatusNotFound
 		if e, ok := err.(HTTPError); ok {
@@ -129,6 +204,10 @@ func (h *Hador) Serve(ctx *Context) {
 		ctx.OnError(status)
 		return
 	}
+	if h.UseRawPath && !unescapeParams(params) {
+		ctx.OnError(http.StatusBadRequest)
+		return
+	}
 	ctx.params = params
 	leaf.Serve(ctx)
 }
@@ -139,9 +218,33 @@ func (h *Hador) AddFilters(filters ...Filter) *Hador {
 	return h
 }
 
+// Register calls fn to add routes without panicking, which is useful for routes
+// loaded from plugins. Routes which can't be registered are skipped, the returned
+// Leaf of them is detached from Hador, so it's safe to keep setting it up.
+// The registration report is returned as RouteErrors, and kept for Validate.
+func (h *Hador) Register(fn func(Router)) error {
+	h.tableMu.Lock()
+	defer h.tableMu.Unlock()
+	errs := h.routes().register(fn)
+	h.routeErrors = append(h.routeErrors, errs...)
+	return errs.err()
+}
+
+// Validate checks the whole routing tree, returns all routes failed in Register
+// and issues found in the tree as RouteErrors, or nil if everything is fine.
+func (h *Hador) Validate() error {
+	errs := append(RouteErrors{}, h.routeErrors...)
+	errs = h.routes().root.validate(nil, errs)
+	return errs.err()
+}
+
 func (h *Hador) travel() []*Leaf {
+	return travelLeaves(h.routes().root)
+}
+
+func travelLeaves(root *node) []*Leaf {
 	llist := list.New()
-	h.root.travel(llist)
+	root.travel(llist)
 
 	leaves := make([]*Leaf, llist.Len())
 	i := 0
@@ -153,20 +256,47 @@ func (h *Hador) travel() []*Leaf {
 }
 
 func (h *Hador) travelPaths() swagger.Paths {
-	spaths := make(swagger.Paths)
-	leaves := h.travel()
+	return travelPaths(h.routes().root, make(swagger.Paths))
+}
+
+// travelPaths adds paths of routes in tree root into spaths.
+func travelPaths(root *node, spaths swagger.Paths) swagger.Paths {
+	leaves := travelLeaves(root)
 	for _, leaf := range leaves {
+		if leaf.mount != nil {
+			prefix := strings.TrimSuffix(leaf.parent.parent.path(), "/")
+			for p, spath := range leaf.mount.travelPaths() {
+				spaths[prefix+p] = spath
+			}
+			continue
+		}
 		if leaf.DocIgnored || leaf.method == "ANY" {
 			continue
 		}
+		// only the first one of alternative leaves is documented.
+		if leaf.parent.leaves[leaf.method] != leaf {
+			continue
+		}
+		operation := *leaf.SwaggerOperation()
+		operation.Parameters = append(swagger.Parameters{}, operation.Parameters...)
 		parent := leaf.parent
 		for parent != nil {
-			if parent.ntype == param {
-				leaf.SwaggerOperation().DocParameterPath(
-					parent.paramName,
-					parent.paramDataType,
-					parent.paramDesc,
-					true)
+			if parent.isParam() {
+				items := swagger.Items{
+					Type:    parent.paramDataType,
+					Format:  parent.paramFormat,
+					Default: parent.paramDefaultValue(),
+				}
+				if d, ok := parent.paramMatcher.(ParamDocumenter); ok {
+					d.DocItems(&items)
+				}
+				operation.DocParameter(swagger.Parameter{
+					Name:        parent.paramName,
+					In:          "path",
+					Description: parent.paramDesc,
+					Required:    !parent.paramOptional,
+					Items:       items,
+				})
 			}
 			parent = parent.parent
 		}
@@ -177,15 +307,23 @@ func (h *Hador) travelPaths() swagger.Paths {
 			spaths[leaf.Path()] = spath
 		}
 
-		spath[strings.ToLower(leaf.Method().String())] = *leaf.SwaggerOperation()
+		spath[strings.ToLower(leaf.Method().String())] = operation
 	}
 	return spaths
 }
 
-// SwaggerHandler returns swagger json api handler
+// SwaggerHandler returns swagger json api handler. Requests of hosts added by Host
+// get the document of the host, which includes routes without host as well.
 func (h *Hador) SwaggerHandler() Handler {
 	h.SwaggerDocument().Paths = h.travelPaths()
+	for _, hr := range h.routes().hosts {
+		h.HostSwaggerDocument(hr.pattern).Paths = travelPaths(hr.root, h.travelPaths())
+	}
 	return HandlerFunc(func(ctx *Context) {
+		if hr, _ := h.routes().matchHost(ctx.Request, ctx.Params()); hr != nil {
+			ctx.RenderJSON(hr.document)
+			return
+		}
 		ctx.RenderJSON(h.SwaggerDocument())
 	})
 }
@@ -221,14 +359,3 @@ func (h *Hador) SwaggerDocument() *swagger.Document {
 	}
 	return h.document
 }
-
-func (h *Hador) showGraph() {
-	leaves := h.travel()
-	for _, l := range leaves {
-		fmt.Println(l.Path())
-	}
-}
-
-func (h *Hador) _showGraph() {
-	h.root._travel("")
-}
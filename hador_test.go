@@ -108,5 +108,96 @@ func TestHodor(t *testing.T) {
 			convey.So(resp.Code, convey.ShouldEqual, http.StatusOK)
 			convey.So(resp.Body.String(), convey.ShouldEqual, "/fuck")
 		})
+		convey.Convey("Test Register", func() {
+			h := New()
+			h.Get("/users/{id:\\d+}", newSimpleHandler("user"))
+			err := h.Register(func(r Router) {
+				r.Get("/plugins/foo", newSimpleHandler("foo"))
+				r.Get("/users/{uid:\\d+}", newSimpleHandler("uid")).
+					SwaggerOperation().
+					DocSumDesc("user name", "")
+			})
+			convey.So(err, convey.ShouldNotBeNil)
+			errs := err.(RouteErrors)
+			convey.So(len(errs), convey.ShouldEqual, 1)
+			convey.So(errs[0].Pattern, convey.ShouldEqual, "/users/{uid:\\d+}")
+			convey.So(errs[0].Conflict, convey.ShouldEqual, "/users/{id:\\d+}")
+
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/plugins/foo", nil)
+			h.ServeHTTP(resp, req)
+			convey.So(resp.Body.String(), convey.ShouldEqual, "foo")
+
+			convey.So(h.Validate(), convey.ShouldResemble, err)
+		})
+		convey.Convey("Test optional params", func() {
+			h := New()
+			h.Get("/items/{page?:\\d+:integer:page=1}", func(ctx *Context) {
+				ctx.WriteString(ctx.Params().GetStringMust("page", ""))
+			})
+			for path, body := range map[string]string{"/items": "1", "/items/": "1", "/items/3": "3"} {
+				resp := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", path, nil)
+				h.ServeHTTP(resp, req)
+				convey.So(resp.Body.String(), convey.ShouldEqual, body)
+			}
+
+			paths := h.travelPaths()
+			convey.So(len(paths), convey.ShouldEqual, 1)
+			params := paths["/items/{page}"]["get"].Parameters
+			convey.So(len(params), convey.ShouldEqual, 1)
+			convey.So(params[0].Required, convey.ShouldBeFalse)
+			convey.So(params[0].Default, convey.ShouldEqual, 1)
+		})
+		convey.Convey("Test Validate", func() {
+			h := New()
+			h.Get("/users/{id}", newSimpleHandler("user"))
+			h.Get("/a/{x}", newSimpleHandler("x"))
+			h.Get("/a/{y*}", newSimpleHandler("y"))
+			convey.So(h.Validate(), convey.ShouldBeNil)
+		})
+	})
+}
+
+func TestRawPath(t *testing.T) {
+	convey.Convey("Given a Hador routing on raw path", t, func() {
+		h := New()
+		h.UseRawPath = true
+		h.Get("/files/{name}", func(ctx *Context) {
+			for _, p := range ctx.Params() {
+				ctx.WriteString(p.Value + " " + p.Raw)
+			}
+		})
+		h.Get("/files/{name}/meta", newSimpleHandler("meta"))
+		h.Get("/café/{name}", func(ctx *Context) {
+			p := ctx.Params()[0]
+			ctx.WriteString(p.Value + " " + p.Raw)
+		})
+		sub := New()
+		sub.UseRawPath = true
+		sub.Get("/{id}", func(ctx *Context) {
+			ctx.WriteString(ctx.Params().GetStringMust("id", "") + "@" + ctx.MountPoint())
+		})
+		h.Mount("/sub", sub)
+
+		serve := func(path string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+
+		convey.So(serve("/files/a%2Fb").Body.String(), convey.ShouldEqual, "a/b a%2Fb")
+		convey.So(serve("/files/100%25").Body.String(), convey.ShouldEqual, "100% 100%25")
+		convey.So(serve("/files/plain").Body.String(), convey.ShouldEqual, "plain plain")
+		convey.So(serve("/files/a%2Fb/meta").Body.String(), convey.ShouldEqual, "meta")
+		convey.So(serve("/sub/x%2Fy").Body.String(), convey.ShouldEqual, "x/y@/sub")
+		convey.So(serve("/caf%C3%A9/cr%C3%A8me%2Fbr%C3%BBl%C3%A9e").Body.String(), convey.ShouldEqual, "crème/brûlée crème%2Fbrûlée")
+		convey.So(serve("/caf%C3%A9/100%2525").Body.String(), convey.ShouldEqual, "100%25 100%2525")
+
+		convey.Convey("Without UseRawPath", func() {
+			h.UseRawPath = false
+			convey.So(serve("/files/a%2Fb").Code, convey.ShouldEqual, http.StatusNotFound)
+		})
 	})
 }
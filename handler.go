@@ -17,7 +17,10 @@
 
 package hador
 
-import "net/http"
+import (
+	"net/http"
+	"reflect"
+)
 
 // Handler interface
 type Handler interface {
@@ -45,6 +48,27 @@ func WrapFunc(hf func(http.ResponseWriter, *http.Request)) HandlerFunc {
 }
 
 func parseHandler(h interface{}) Handler {
+	handler, err := tryParseHandler(h)
+	if err != nil {
+		panic(err)
+	}
+	return handler
+}
+
+// tryParseHandler converts h into Handler. Functions other than the known shapes are
+// treated as typed handlers, and an error is returned if the signature isn't supported.
+func tryParseHandler(h interface{}) (Handler, error) {
+	switch h.(type) {
+	case Handler, func(*Context), http.Handler, func(http.ResponseWriter, *http.Request):
+		return parseSimpleHandler(h), nil
+	}
+	if t := reflect.TypeOf(h); t != nil && t.Kind() == reflect.Func {
+		return newTypedHandler(h)
+	}
+	return parseSimpleHandler(h), nil
+}
+
+func parseSimpleHandler(h interface{}) Handler {
 	switch v := h.(type) {
 	case Handler:
 		return v
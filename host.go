@@ -0,0 +1,123 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Xuyuanp/hador/swagger"
+)
+
+// hostRouter holds routes of requests whose host matches pattern.
+type hostRouter struct {
+	pattern string
+	// matcher matches host as path, e.g. "/{tenant}.example.com".
+	matcher  *node
+	root     *node
+	document *swagger.Document
+}
+
+func newHostRouter(pattern string) *hostRouter {
+	hr := &hostRouter{
+		pattern: pattern,
+		matcher: &node{},
+		root:    &node{},
+	}
+	// the leaf only marks the end of pattern, it never serves.
+	hr.matcher.AddRoute(GET, "/"+pattern, Status(http.StatusNotFound))
+	return hr
+}
+
+// match matches host and appends host params into params.
+func (hr *hostRouter) match(host string, params Params) (Params, bool) {
+	params, _, err := hr.matcher.match(GET, "/"+host, params)
+	return params, err == nil
+}
+
+// maxParams returns max count of params of this host, host params are kept
+// when falling back to the default routes, whose max count is fallbackMax.
+func (hr *hostRouter) maxParams(fallbackMax int) int {
+	pathMax := hr.root.findMaxParams()
+	if fallbackMax > pathMax {
+		pathMax = fallbackMax
+	}
+	return hr.matcher.findMaxParams() + pathMax
+}
+
+// Host adds routes only for requests whose host matches pattern, host params like
+// "{tenant}.example.com" are captured into Params. Requests no route of the host matches
+// fall back to routes added without host, with host params kept.
+// Like AddRoute, Host should be called before serving, use UpdateRoutes at runtime.
+func (h *Hador) Host(pattern string, fn func(Router), filters ...Filter) {
+	h.tableMu.Lock()
+	defer h.tableMu.Unlock()
+
+	t := h.routes()
+	hr := t.hostRouter(pattern)
+	if hr == nil {
+		hr = newHostRouter(strings.ToLower(pattern))
+		t.hosts = append(t.hosts, hr)
+	}
+	hr.root.router().Group("", fn, filters...)
+	t.maxParams = t.findMaxParams()
+}
+
+func (t *routeTable) hostRouter(pattern string) *hostRouter {
+	pattern = strings.ToLower(pattern)
+	for _, hr := range t.hosts {
+		if hr.pattern == pattern {
+			return hr
+		}
+	}
+	return nil
+}
+
+// matchHost returns the first hostRouter matching host of request, with host params appended.
+func (t *routeTable) matchHost(req *http.Request, params Params) (*hostRouter, Params) {
+	if len(t.hosts) == 0 {
+		return nil, params
+	}
+	host := req.Host
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	host = strings.ToLower(host)
+	for _, hr := range t.hosts {
+		if ps, ok := hr.match(host, params); ok {
+			return hr, ps
+		}
+	}
+	return nil, params
+}
+
+// HostSwaggerDocument returns swagger.Document of routes added by Host with pattern.
+// It's a copy of SwaggerDocument with Host set as pattern on creation.
+func (h *Hador) HostSwaggerDocument(pattern string) *swagger.Document {
+	hr := h.routes().hostRouter(pattern)
+	if hr == nil {
+		return nil
+	}
+	if hr.document == nil {
+		doc := *h.SwaggerDocument()
+		doc.Host = hr.pattern
+		hr.document = &doc
+	}
+	return hr.document
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Xuyuanp/hador/swagger"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestHost(t *testing.T) {
+	convey.Convey("Given a Hador with hosts", t, func() {
+		h := New()
+		h.Get("/hello", newSimpleHandler("default"))
+		h.Post("/hello", newSimpleHandler("default post"))
+		h.Get("/tenant", func(ctx *Context) {
+			ctx.WriteString(ctx.Params().GetStringMust("tenant", "none"))
+		})
+		h.Host("api.example.com", func(r Router) {
+			r.Get("/hello", newSimpleHandler("api"))
+		})
+		h.Host("{tenant}.example.com", func(r Router) {
+			r.Get("/users/{id}", func(ctx *Context) {
+				params := ctx.Params()
+				ctx.WriteString(params.GetStringMust("tenant", "") + ":" + params.GetStringMust("id", ""))
+			})
+		})
+
+		serve := func(method, host, path string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, path, nil)
+			req.Host = host
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+
+		convey.Convey("static host", func() {
+			convey.So(serve("GET", "api.example.com", "/hello").Body.String(), convey.ShouldEqual, "api")
+			convey.So(serve("GET", "API.example.com:8080", "/hello").Body.String(), convey.ShouldEqual, "api")
+		})
+		convey.Convey("host params", func() {
+			convey.So(serve("GET", "acme.example.com", "/users/42").Body.String(), convey.ShouldEqual, "acme:42")
+			convey.So(serve("POST", "acme.example.com", "/users/42").Code, convey.ShouldEqual, http.StatusMethodNotAllowed)
+		})
+		convey.Convey("fall back to default routes", func() {
+			convey.So(serve("GET", "acme.example.com", "/hello").Body.String(), convey.ShouldEqual, "default")
+			convey.So(serve("GET", "acme.example.com", "/tenant").Body.String(), convey.ShouldEqual, "acme")
+			convey.So(serve("GET", "localhost", "/tenant").Body.String(), convey.ShouldEqual, "none")
+			convey.So(serve("GET", "localhost", "/users/42").Code, convey.ShouldEqual, http.StatusNotFound)
+			convey.So(serve("POST", "api.example.com", "/hello").Body.String(), convey.ShouldEqual, "default post")
+			convey.So(serve("DELETE", "api.example.com", "/hello").Code, convey.ShouldEqual, http.StatusMethodNotAllowed)
+		})
+		convey.Convey("leaves are kept by later hosts", func() {
+			admin := h.Get("/admin", newSimpleHandler("admin"))
+			h.Host("api.example.com", func(r Router) {
+				r.Get("/bye", newSimpleHandler("bye"))
+			})
+			admin.Name("admin").MatchHeader("X-Token", "^secret$")
+			convey.So(serve("GET", "localhost", "/admin").Code, convey.ShouldEqual, http.StatusNotFound)
+			url, err := h.URLFor("admin")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(url, convey.ShouldEqual, "/admin")
+			convey.So(serve("GET", "api.example.com", "/bye").Body.String(), convey.ShouldEqual, "bye")
+			convey.So(serve("GET", "api.example.com", "/hello").Body.String(), convey.ShouldEqual, "api")
+		})
+		convey.Convey("swagger document per host", func() {
+			h.SwaggerDocument().DocHost("example.com")
+			h.Swagger(SwaggerConfig{APIPath: "/apidocs.json"})
+			convey.So(h.HostSwaggerDocument("unknown.com"), convey.ShouldBeNil)
+
+			var doc swagger.Document
+			resp := serve("GET", "acme.example.com", "/apidocs.json")
+			convey.So(json.Unmarshal(resp.Body.Bytes(), &doc), convey.ShouldBeNil)
+			convey.So(doc.Host, convey.ShouldEqual, "{tenant}.example.com")
+			_, ok := doc.Paths["/users/{id}"]
+			convey.So(ok, convey.ShouldBeTrue)
+			_, ok = doc.Paths["/hello"]
+			convey.So(ok, convey.ShouldBeTrue)
+
+			doc = swagger.Document{}
+			resp = serve("GET", "localhost", "/apidocs.json")
+			convey.So(json.Unmarshal(resp.Body.Bytes(), &doc), convey.ShouldBeNil)
+			convey.So(doc.Host, convey.ShouldEqual, "example.com")
+			_, ok = doc.Paths["/users/{id}"]
+			convey.So(ok, convey.ShouldBeFalse)
+		})
+	})
+}
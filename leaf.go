@@ -17,7 +17,11 @@
 
 package hador
 
-import "github.com/Xuyuanp/hador/swagger"
+import (
+	"net/http"
+
+	"github.com/Xuyuanp/hador/swagger"
+)
 
 // Leaf struct
 type Leaf struct {
@@ -26,6 +30,17 @@ type Leaf struct {
 	path    string
 	handler Handler
 	method  Method
+	name    string
+	// filters added by AddFilters, kept for Routes.
+	filters []Filter
+
+	matchers []Matcher
+	// next is the alternative Leaf with the same method and path.
+	next *Leaf
+	// primary is the Leaf this alias of optional params serves by.
+	primary *Leaf
+	// mount is the Hador mounted at this Leaf, whose paths are merged into document.
+	mount *Hador
 
 	DocIgnored bool
 	operation  *swagger.Operation
@@ -43,6 +58,26 @@ func NewLeaf(parent *node, method Method, handler Handler) *Leaf {
 	return l
 }
 
+// newDetachedLeaf creates a Leaf which belongs to no node, it never serves.
+func newDetachedLeaf(method Method, pattern string) *Leaf {
+	l := &Leaf{
+		path:       pattern,
+		method:     method,
+		handler:    Status(http.StatusNotFound),
+		DocIgnored: true,
+	}
+	l.FilterChain = NewFilterChain(l.handler)
+	return l
+}
+
+// aliasHandler returns a Handler serving by this Leaf with defaults appended into Params.
+func (l *Leaf) aliasHandler(defaults Params) Handler {
+	return HandlerFunc(func(ctx *Context) {
+		ctx.params = append(ctx.params, defaults...)
+		l.Serve(ctx)
+	})
+}
+
 // Path returns the full path from root to the parent node
 func (l *Leaf) Path() string {
 	return l.path
@@ -58,9 +93,27 @@ func (l *Leaf) Handler() Handler {
 	return l.handler
 }
 
+// operationDocumenter is implemented by handlers and filters documenting operations
+// of routes they're added to.
+type operationDocumenter interface {
+	docOperation(method Method, op *swagger.Operation)
+}
+
 // AddFilters add filters into FilterChain
 func (l *Leaf) AddFilters(filters ...Filter) *Leaf {
 	l.FilterChain.AddFilters(filters...)
+	l.filters = append(l.filters, filters...)
+	for _, f := range filters {
+		if d, ok := f.(operationDocumenter); ok {
+			d.docOperation(l.method, l.SwaggerOperation())
+		}
+	}
+	return l
+}
+
+// Name sets name of this route, which is reported by Routes.
+func (l *Leaf) Name(name string) *Leaf {
+	l.name = name
 	return l
 }
 
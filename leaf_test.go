@@ -35,6 +35,6 @@ func TestLeaf(t *testing.T) {
 		for parent != nil && parent.parent != nil {
 			parent = parent.parent
 		}
-		convey.So(parent, convey.ShouldEqual, h.root)
+		convey.So(parent, convey.ShouldEqual, h.routes().root)
 	})
 }
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Matcher decides whether a request could be served by a Leaf, in addition to
+// method and path.
+type Matcher interface {
+	Match(req *http.Request) bool
+}
+
+// MatcherFunc is Matcher as function
+type MatcherFunc func(req *http.Request) bool
+
+// Match implements Matcher interface by calling MatcherFunc function
+func (mf MatcherFunc) Match(req *http.Request) bool {
+	return mf(req)
+}
+
+// HeaderMatcher matches requests whose header value of key matches regexp pattern.
+func HeaderMatcher(key, pattern string) Matcher {
+	reg := regexp.MustCompile(pattern)
+	return MatcherFunc(func(req *http.Request) bool {
+		for _, value := range req.Header[http.CanonicalHeaderKey(key)] {
+			if reg.MatchString(value) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// QueryMatcher matches requests with query parameter key present.
+func QueryMatcher(key string) Matcher {
+	return MatcherFunc(func(req *http.Request) bool {
+		_, ok := req.URL.Query()[key]
+		return ok
+	})
+}
+
+// ContentTypeMatcher matches requests whose Content-Type is one of mimeTypes,
+// wildcard subtype like "text/*" is supported.
+func ContentTypeMatcher(mimeTypes ...string) Matcher {
+	return MatcherFunc(func(req *http.Request) bool {
+		mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if err != nil {
+			return false
+		}
+		for _, mimeType := range mimeTypes {
+			mimeType = strings.ToLower(mimeType)
+			if mimeType == mediaType ||
+				(strings.HasSuffix(mimeType, "/*") && strings.HasPrefix(mediaType, mimeType[:len(mimeType)-1])) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Match adds matchers into Leaf. Several leaves with matchers could share the same
+// method and path, the first one whose matchers all match a request serves it.
+// If none of them matches, other routes matching the path are tried, e.g. "/items/{id}"
+// for "/items/new", and the request is treated as not found if nothing else matches.
+func (l *Leaf) Match(matchers ...Matcher) *Leaf {
+	l.matchers = append(l.matchers, matchers...)
+	return l
+}
+
+// MatchFunc adds a MatcherFunc into Leaf.
+func (l *Leaf) MatchFunc(f func(*http.Request) bool) *Leaf {
+	return l.Match(MatcherFunc(f))
+}
+
+// MatchHeader adds a HeaderMatcher into Leaf.
+func (l *Leaf) MatchHeader(key, pattern string) *Leaf {
+	return l.Match(HeaderMatcher(key, pattern))
+}
+
+// MatchQuery adds a QueryMatcher into Leaf.
+func (l *Leaf) MatchQuery(key string) *Leaf {
+	return l.Match(QueryMatcher(key))
+}
+
+// MatchContentType adds a ContentTypeMatcher into Leaf.
+func (l *Leaf) MatchContentType(mimeTypes ...string) *Leaf {
+	return l.Match(ContentTypeMatcher(mimeTypes...))
+}
+
+// constrained returns whether this Leaf has any matcher.
+func (l *Leaf) constrained() bool {
+	if l.primary != nil {
+		return l.primary.constrained()
+	}
+	return len(l.matchers) > 0
+}
+
+func (l *Leaf) matches(req *http.Request) bool {
+	if l.primary != nil {
+		return l.primary.matches(req)
+	}
+	for _, m := range l.matchers {
+		if !m.Match(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// pick returns the first Leaf in the chain starting from l matching req, nil if none.
+func (l *Leaf) pick(req *http.Request) *Leaf {
+	for ; l != nil; l = l.next {
+		if l.matches(req) {
+			return l
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestMatcher(t *testing.T) {
+	convey.Convey("Given a Hador with constrained routes", t, func() {
+		h := New()
+		h.Get("/items", newSimpleHandler("v2")).MatchHeader("Accept", `application/vnd\.x\.v2\+json`)
+		h.Get("/items", newSimpleHandler("query")).MatchQuery("q")
+		h.Get("/items", newSimpleHandler("v1"))
+		h.Post("/items", newSimpleHandler("json")).MatchContentType("application/json")
+		h.Post("/items", newSimpleHandler("text")).MatchContentType("text/*")
+		h.Post("/items", newSimpleHandler("custom")).MatchFunc(func(req *http.Request) bool {
+			return req.ContentLength == 0
+		})
+		h.Get("/optional/{page?}", newSimpleHandler("optional")).MatchQuery("q")
+		h.Get("/things/new", newSimpleHandler("new")).MatchQuery("x")
+		h.Get("/things/{id}", newSimpleHandler("thing"))
+
+		serve := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, path, nil)
+			for k, v := range header {
+				req.Header[k] = v
+			}
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+
+		convey.Convey("header", func() {
+			resp := serve("GET", "/items", http.Header{"Accept": {"application/vnd.x.v2+json"}})
+			convey.So(resp.Body.String(), convey.ShouldEqual, "v2")
+		})
+		convey.Convey("query", func() {
+			convey.So(serve("GET", "/items?q=", nil).Body.String(), convey.ShouldEqual, "query")
+			convey.So(serve("GET", "/items", nil).Body.String(), convey.ShouldEqual, "v1")
+		})
+		convey.Convey("content type", func() {
+			resp := serve("POST", "/items", http.Header{"Content-Type": {"application/json; charset=utf-8"}})
+			convey.So(resp.Body.String(), convey.ShouldEqual, "json")
+			resp = serve("POST", "/items", http.Header{"Content-Type": {"text/plain"}})
+			convey.So(resp.Body.String(), convey.ShouldEqual, "text")
+		})
+		convey.Convey("custom func", func() {
+			resp := serve("POST", "/items", http.Header{"Content-Type": {"image/png"}})
+			convey.So(resp.Body.String(), convey.ShouldEqual, "custom")
+		})
+		convey.Convey("none matches", func() {
+			convey.So(serve("GET", "/optional", nil).Code, convey.ShouldEqual, http.StatusNotFound)
+			convey.So(serve("GET", "/optional?q=1", nil).Body.String(), convey.ShouldEqual, "optional")
+		})
+		convey.Convey("backtrack to other routes", func() {
+			convey.So(serve("GET", "/things/new?x=1", nil).Body.String(), convey.ShouldEqual, "new")
+			convey.So(serve("GET", "/things/new", nil).Body.String(), convey.ShouldEqual, "thing")
+		})
+		convey.Convey("unconstrained route should be the last one", func() {
+			convey.So(func() { h.Get("/items", newSimpleHandler("v3")) }, convey.ShouldPanic)
+		})
+		convey.Convey("only the first one documented", func() {
+			h.Get("/hello", newSimpleHandler("hello")).
+				MatchQuery("q").
+				SwaggerOperation().
+				DocSumDesc("first", "")
+			h.Get("/hello", newSimpleHandler("hello")).
+				SwaggerOperation().
+				DocSumDesc("second", "")
+			paths := h.travelPaths()
+			convey.So(paths["/hello"]["get"].Summary, convey.ShouldEqual, "first")
+		})
+	})
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// mountParam is name of the matchAll param capturing path under the mount point.
+const mountParam = "hador-mount-path"
+
+type mountPointKey struct{}
+
+// MountPoint returns the path prefix where the app serving this request is mounted,
+// including the mount points of all parents. Empty if not mounted.
+func (ctx *Context) MountPoint() string {
+	mp, _ := ctx.Request.Context().Value(mountPointKey{}).(string)
+	return mp
+}
+
+// mounted serves requests by handler with the mount point stripped from path.
+type mounted struct {
+	handler     Handler
+	httpHandler http.Handler
+	app         *Hador
+}
+
+func newMounted(handler interface{}) *mounted {
+	m := &mounted{}
+	switch v := handler.(type) {
+	case *Hador:
+		m.app = v
+		m.httpHandler = v
+	case http.Handler:
+		m.httpHandler = v
+	default:
+		m.handler = parseHandler(handler)
+	}
+	return m
+}
+
+func (m *mounted) Serve(ctx *Context) {
+	req := ctx.Request
+	path := req.URL.Path
+	trimmed := path
+	if len(trimmed) > 1 && trimmed[len(trimmed)-1] == '/' {
+		trimmed = trimmed[:len(trimmed)-1]
+	}
+	rest := ctx.Params().GetStringMust(mountParam, "")
+	prefix := strings.TrimSuffix(trimmed[:len(trimmed)-len(rest)], "/")
+
+	sub := req.WithContext(context.WithValue(req.Context(), mountPointKey{}, ctx.MountPoint()+prefix))
+	u := *req.URL
+	u.Path = path[len(prefix):]
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawPath = ""
+	if raw := req.URL.RawPath; raw != "" {
+		// strip as many segments as prefix has, it's ignored by URL.EscapedPath
+		// if turns out not to be an encoding of Path.
+		for n := strings.Count(prefix, "/"); n > 0 && raw != ""; n-- {
+			if i := strings.IndexByte(raw[1:], '/'); i >= 0 {
+				raw = raw[i+1:]
+			} else {
+				raw = ""
+			}
+		}
+		u.RawPath = raw
+	}
+	sub.URL = &u
+
+	if m.httpHandler != nil {
+		m.httpHandler.ServeHTTP(ctx.Response, sub)
+		return
+	}
+	ctx.Request = sub
+	m.handler.Serve(ctx)
+	ctx.Request = req
+}
+
+// Mount serves all requests under prefix by handler, with prefix stripped from request path.
+// handler could be an independent *Hador with its own filters, Logger and swagger document,
+// any http.Handler, or anything else AddRoute accepts. The mount point is available by
+// Context.MountPoint, and paths of a mounted *Hador are merged into the swagger document.
+func (r RouterFunc) Mount(prefix string, handler interface{}, filters ...Filter) {
+	prefix = strings.TrimRight(prefix, "/")
+	m := newMounted(handler)
+	exact := prefix
+	if exact == "" {
+		exact = "/"
+	}
+	for _, method := range Methods {
+		r.AddRoute(method, exact, m, filters...).DocIgnore(true)
+		leaf := r.AddRoute(method, prefix+"/{"+mountParam+"*}", m, filters...).DocIgnore(true)
+		if method == GET {
+			leaf.mount = m.app
+		}
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestMount(t *testing.T) {
+	convey.Convey("Given a Hador with mounted apps", t, func() {
+		billing := New()
+		billing.AddFilters(FilterFunc(func(ctx *Context, next Handler) {
+			ctx.SetHeader("X-App", "billing")
+			next.Serve(ctx)
+		}))
+		billing.Get("/", func(ctx *Context) {
+			ctx.WriteString("index@" + ctx.MountPoint())
+		})
+		billing.Get("/invoices/{id}", func(ctx *Context) {
+			ctx.WriteString(ctx.Request.URL.Path + "@" + ctx.MountPoint())
+		}).SwaggerOperation().DocSumDesc("get invoice", "")
+
+		h := New()
+		h.Get("/hello", newSimpleHandler("hello"))
+		h.Group("/v1", func(r Router) {
+			r.Mount("/billing", billing)
+		})
+		h.Mount("/static", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(req.URL.Path))
+		}))
+		h.Mount("/echo/", func(ctx *Context) {
+			ctx.WriteString(ctx.Request.URL.Path + "@" + ctx.MountPoint())
+		})
+
+		serve := func(method, path string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, path, nil)
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+
+		convey.Convey("mounted Hador", func() {
+			resp := serve("GET", "/v1/billing/invoices/42")
+			convey.So(resp.Body.String(), convey.ShouldEqual, "/invoices/42@/v1/billing")
+			convey.So(resp.Header().Get("X-App"), convey.ShouldEqual, "billing")
+			convey.So(serve("GET", "/v1/billing").Body.String(), convey.ShouldEqual, "index@/v1/billing")
+			convey.So(serve("GET", "/v1/billing/").Body.String(), convey.ShouldEqual, "index@/v1/billing")
+			convey.So(serve("POST", "/v1/billing/invoices/42").Code, convey.ShouldEqual, http.StatusMethodNotAllowed)
+		})
+		convey.Convey("mounted http.Handler", func() {
+			convey.So(serve("GET", "/static/css/main.css").Body.String(), convey.ShouldEqual, "/css/main.css")
+		})
+		convey.Convey("mounted handler func", func() {
+			convey.So(serve("DELETE", "/echo/a/b/").Body.String(), convey.ShouldEqual, "/a/b/@/echo")
+		})
+		convey.Convey("routes besides mount points", func() {
+			convey.So(serve("GET", "/hello").Body.String(), convey.ShouldEqual, "hello")
+		})
+		convey.Convey("paths merged into document", func() {
+			paths := h.travelPaths()
+			convey.So(paths["/v1/billing/invoices/{id}"]["get"].Summary, convey.ShouldEqual, "get invoice")
+			_, ok := paths["/static/{"+mountParam+"}"]
+			convey.So(ok, convey.ShouldBeFalse)
+		})
+	})
+}
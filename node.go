@@ -20,7 +20,10 @@ package hador
 import (
 	"container/list"
 	"fmt"
+	"net/http"
 	"regexp"
+	"strconv"
+	"strings"
 )
 
 type nodeType int
@@ -28,22 +31,30 @@ type nodeType int
 const (
 	static nodeType = iota
 	param
+	// matchAll param matches the rest of path, e.g. "/static/{filepath*}".
 	matchAll
 )
 
 type node struct {
-	parent     *node
-	segment    string
-	indices    string
-	children   []*node
-	paramChild *node
-	ntype      nodeType
-	leaves     map[Method]*Leaf
+	parent   *node
+	segment  string
+	indices  string
+	children []*node
+	ntype    nodeType
+	leaves   map[Method]*Leaf
+	// alternative param nodes at the same position, tried in order.
+	paramChildren []*node
 
 	paramName     string
 	paramReg      *regexp.Regexp
+	paramRegstr   string // the rule paramReg is compiled from
+	paramType     string
+	paramMatcher  ParamMatcher // checks the param constrained by paramType
+	paramFormat   string
 	paramDataType string
 	paramDesc     string
+	paramOptional bool
+	paramDefault  string
 }
 
 func (n *node) router() Router {
@@ -51,21 +62,51 @@ func (n *node) router() Router {
 }
 
 func (n *node) AddRoute(method Method, pattern string, handler interface{}, filters ...Filter) *Leaf {
-	if len(pattern) == 0 || pattern[0] != '/' {
-		panic("pattern should start with '/', pattern: " + pattern)
+	l, err := n.tryAddRoute(method, pattern, handler, filters...)
+	if err != nil {
+		panic(err)
 	}
+	return l
+}
+
+// tryAddRoute does the same work as AddRoute, but returns a *RouteError instead of panicking.
+func (n *node) tryAddRoute(method Method, pattern string, handler interface{}, filters ...Filter) (*Leaf, error) {
 	if len(pattern) > 1 && pattern[len(pattern)-1] == '/' {
 		pattern = pattern[:len(pattern)-1]
 	}
+	l, err := n.checkAndAddRoute(method, pattern, handler, filters...)
+	if err != nil {
+		e := err.(*RouteError)
+		e.Method = method
+		e.Pattern = pattern
+		return nil, e
+	}
+	return l, nil
+}
+
+func (n *node) checkAndAddRoute(method Method, pattern string, handler interface{}, filters ...Filter) (*Leaf, error) {
+	if len(pattern) == 0 || pattern[0] != '/' {
+		return nil, &RouteError{Reason: "pattern should start with '/'"}
+	}
 	if handler == nil {
-		panic("handler should NOT be nil")
+		return nil, &RouteError{Reason: "handler should NOT be nil"}
+	}
+	if err := checkPattern(pattern); err != nil {
+		return nil, err
 	}
 	for _, m := range Methods {
 		if m == method {
-			return n.addRoute(method, pattern, parseHandler(handler), filters...)
+			h, err := tryParseHandler(handler)
+			if err != nil {
+				return nil, &RouteError{Reason: err.Error()}
+			}
+			if strings.Contains(pattern, "?") {
+				return n.addOptionalRoute(method, pattern, h, filters...)
+			}
+			return n.addRoute(method, pattern, h, filters...)
 		}
 	}
-	panic("unknown method: " + method)
+	return nil, &RouteError{Reason: "unknown method"}
 }
 
 func min(first, second int) int {
@@ -75,7 +116,7 @@ func min(first, second int) int {
 	return second
 }
 
-func (n *node) addRoute(method Method, pattern string, handler Handler, filters ...Filter) *Leaf {
+func (n *node) addRoute(method Method, pattern string, handler Handler, filters ...Filter) (*Leaf, error) {
 	if len(n.segment) == 0 {
 		return n.init(method, pattern, handler, filters...)
 	}
@@ -91,23 +132,41 @@ func (n *node) addRoute(method Method, pattern string, handler Handler, filters
 		return n.insertChild(method, pattern[i:], handler, filters...)
 	}
 
-	if n.ntype == param {
-		i, max := 0, len(pattern)
-		for i < max && pattern[i] != '}' {
-			i++
-		}
-		if i == max {
-			panic("missing '}'")
-		}
+	if n.isParam() {
+		i := strings.IndexByte(pattern, '}')
 		if n.segment != pattern[:i+1] {
-			panic("conflict param node")
-		}
-		if i < max-1 && pattern[i+1] != '/' {
-			panic("'}' should be before '/'")
+			return nil, &RouteError{
+				Reason:   "conflict param node",
+				Conflict: n.rawPath(),
+			}
 		}
 		return n.insertChild(method, pattern[i+1:], handler, filters...)
 	}
-	return nil
+	return nil, &RouteError{Reason: "unknown node type"}
+}
+
+// addOptionalRoute adds the route with all optional params present, and aliases of it
+// with the optional params omitted, which fill the defaults into Params before serving.
+func (n *node) addOptionalRoute(method Method, pattern string, handler Handler, filters ...Filter) (*Leaf, error) {
+	l, err := n.addRoute(method, pattern, handler, filters...)
+	if err != nil {
+		return nil, err
+	}
+	aliases := []*Leaf{l}
+	for _, r := range expandOptional(pattern) {
+		alias, err := n.addRoute(method, r.pattern, l.aliasHandler(r.defaults))
+		if err != nil {
+			for _, a := range aliases {
+				a.parent.removeLeaf(a)
+				a.parent.prune()
+			}
+			return nil, err
+		}
+		alias.DocIgnored = true
+		alias.primary = l
+		aliases = append(aliases, alias)
+	}
+	return l, nil
 }
 
 func (n *node) splitAt(index int) {
@@ -115,30 +174,33 @@ func (n *node) splitAt(index int) {
 		return
 	}
 	next := &node{
-		parent:     n,
-		segment:    n.segment[index:],
-		indices:    n.indices,
-		children:   n.children,
-		leaves:     n.leaves,
-		ntype:      n.ntype,
-		paramChild: n.paramChild,
-	}
-	if next.children != nil {
-		for _, ch := range next.children {
-			ch.parent = next
+		parent:        n,
+		segment:       n.segment[index:],
+		indices:       n.indices,
+		children:      n.children,
+		leaves:        n.leaves,
+		ntype:         n.ntype,
+		paramChildren: n.paramChildren,
+	}
+	for _, ch := range next.children {
+		ch.parent = next
+	}
+	for _, ch := range next.paramChildren {
+		ch.parent = next
+	}
+	for _, l := range next.leaves {
+		for ; l != nil; l = l.next {
+			l.parent = next
 		}
 	}
-	if next.paramChild != nil {
-		next.paramChild.parent = next
-	}
 	n.indices = n.segment[index : index+1]
 	n.segment = n.segment[:index]
 	n.children = []*node{next}
-	n.paramChild = nil
+	n.paramChildren = nil
 	n.leaves = nil
 }
 
-func (n *node) insertChild(method Method, pattern string, handler Handler, filters ...Filter) *Leaf {
+func (n *node) insertChild(method Method, pattern string, handler Handler, filters ...Filter) (*Leaf, error) {
 	if len(pattern) == 0 {
 		return n.handle(method, handler, filters...)
 	}
@@ -148,11 +210,9 @@ func (n *node) insertChild(method Method, pattern string, handler Handler, filte
 	return n.insertStaticChild(method, pattern, handler, filters...)
 }
 
-func (n *node) insertStaticChild(method Method, pattern string, handler Handler, filters ...Filter) *Leaf {
-	for i, ind := range n.indices {
-		if ind == rune(pattern[0]) {
-			return n.children[i].addRoute(method, pattern, handler, filters...)
-		}
+func (n *node) insertStaticChild(method Method, pattern string, handler Handler, filters ...Filter) (*Leaf, error) {
+	if child := n.staticChild(pattern[0]); child != nil {
+		return child.addRoute(method, pattern, handler, filters...)
 	}
 	n.indices += pattern[:1]
 	child := &node{parent: n}
@@ -160,28 +220,72 @@ func (n *node) insertStaticChild(method Method, pattern string, handler Handler,
 	return child.addRoute(method, pattern, handler, filters...)
 }
 
-func (n *node) insertParamChild(method Method, pattern string, handler Handler, filters ...Filter) *Leaf {
-	if n.paramChild == nil {
-		n.paramChild = &node{parent: n}
+func (n *node) insertParamChild(method Method, pattern string, handler Handler, filters ...Filter) (*Leaf, error) {
+	segment := pattern[:strings.IndexByte(pattern, '}')+1]
+	name, regstr, _, _, _ := readParam(segment)
+	for _, ch := range n.paramChildren {
+		if ch.segment == segment {
+			return ch.addRoute(method, pattern, handler, filters...)
+		}
+	}
+	all := strings.HasSuffix(name, "*")
+	for _, ch := range n.paramChildren {
+		if ch.paramRule() == regstr && (ch.ntype == matchAll) == all {
+			return nil, &RouteError{
+				Reason:   "ambiguous param node " + name,
+				Conflict: ch.rawPath(),
+			}
+		}
+	}
+	child := &node{parent: n}
+	rank := paramRank(regstr != "", all)
+	i := len(n.paramChildren)
+	for i > 0 && n.paramChildren[i-1].rank() > rank {
+		i--
+	}
+	n.paramChildren = append(n.paramChildren, nil)
+	copy(n.paramChildren[i+1:], n.paramChildren[i:])
+	n.paramChildren[i] = child
+	return child.addRoute(method, pattern, handler, filters...)
+}
+
+// paramRank returns the order in which param nodes are tried: params constrained by
+// regexp are more specific than the others, and matchAll ones are tried at last.
+func paramRank(constrained, all bool) int {
+	rank := 0
+	if !constrained {
+		rank++
+	}
+	if all {
+		rank += 2
+	}
+	return rank
+}
+
+func (n *node) rank() int {
+	return paramRank(n.paramRule() != "", n.ntype == matchAll)
+}
+
+// paramRule returns the regexp or ParamType constraining the param.
+func (n *node) paramRule() string {
+	if n.paramReg != nil {
+		return n.paramRegstr
 	}
-	return n.paramChild.addRoute(method, pattern, handler, filters...)
+	return n.paramType
 }
 
-func (n *node) init(method Method, pattern string, handler Handler, filters ...Filter) *Leaf {
+func (n *node) init(method Method, pattern string, handler Handler, filters ...Filter) (*Leaf, error) {
 	if pattern[0] == '{' {
 		return n.initParam(method, pattern, handler, filters...)
 	}
 	return n.initStatic(method, pattern, handler, filters...)
 }
 
-func (n *node) initStatic(method Method, pattern string, handler Handler, filters ...Filter) *Leaf {
+func (n *node) initStatic(method Method, pattern string, handler Handler, filters ...Filter) (*Leaf, error) {
 	i, max := 0, len(pattern)
 	for i < max && pattern[i] != '{' {
 		i++
 	}
-	if i < max && i > 0 && pattern[i-1] != '/' {
-		panic("'{' should be after '/'")
-	}
 
 	n.segment = pattern[:i]
 	n.ntype = static
@@ -191,19 +295,32 @@ func (n *node) initStatic(method Method, pattern string, handler Handler, filter
 	return n.insertChild(method, pattern[i:], handler, filters...)
 }
 
-func (n *node) initParam(method Method, pattern string, handler Handler, filters ...Filter) *Leaf {
+func (n *node) initParam(method Method, pattern string, handler Handler, filters ...Filter) (*Leaf, error) {
 	name, regstr, dataType, desc, rest := readParam(pattern)
-	if len(name) == 0 {
-		panic("empty param name")
-	}
+	name, desc, n.paramDefault, n.paramOptional = parseOptional(name, desc)
 	n.paramName = name
 	if len(regstr) > 0 {
-		n.paramReg = regexp.MustCompile(regstr)
+		if pt, m, ok, _ := parseParamType(regstr); ok {
+			n.paramMatcher, n.paramType, n.paramFormat = m, regstr, pt.Format
+			if dataType == "" {
+				dataType = pt.DataType
+			}
+		} else {
+			n.paramReg = regexp.MustCompile(anchorRule(regstr))
+			n.paramRegstr = regstr
+		}
+	}
+	if dataType == "" {
+		dataType = "string"
 	}
 	n.paramDataType = dataType
 	n.paramDesc = desc
 
 	n.ntype = param
+	if strings.HasSuffix(name, "*") {
+		n.paramName = name[:len(name)-1]
+		n.ntype = matchAll
+	}
 	n.segment = pattern[:len(pattern)-len(rest)]
 	n.indices = ""
 	n.children = nil
@@ -211,22 +328,225 @@ func (n *node) initParam(method Method, pattern string, handler Handler, filters
 	return n.insertChild(method, rest, handler, filters...)
 }
 
-func (n *node) handle(method Method, handler Handler, filters ...Filter) *Leaf {
-	if _, ok := n.leaves[method]; ok {
-		panic("route has been registered")
+func (n *node) handle(method Method, handler Handler, filters ...Filter) (*Leaf, error) {
+	// leaves with the same method are chained, and a new one is allowed only
+	// if the last one has matchers.
+	last, ok := n.leaves[method]
+	for ok && last.next != nil {
+		last = last.next
+	}
+	if ok && !last.constrained() {
+		return nil, &RouteError{
+			Reason:   "route has been registered",
+			Conflict: n.rawPath(),
+		}
 	}
 	l := NewLeaf(n, method, handler)
+	if th, ok := handler.(*typedHandler); ok {
+		th.docOperation(method, l.SwaggerOperation())
+	}
 	if n.leaves == nil {
 		n.leaves = make(map[Method]*Leaf)
 	}
-	n.leaves[method] = l
+	if ok {
+		last.next = l
+	} else {
+		n.leaves[method] = l
+	}
 	l.AddFilters(filters...)
-	return l
+	return l, nil
+}
+
+// paramDefaultValue returns the default value of param converted by its data type
+// for document, nil if there isn't one.
+func (n *node) paramDefaultValue() interface{} {
+	if n.paramDefault == "" {
+		return nil
+	}
+	switch n.paramDataType {
+	case "integer":
+		if v, err := strconv.ParseInt(n.paramDefault, 10, 64); err == nil {
+			return v
+		}
+	case "number":
+		if v, err := strconv.ParseFloat(n.paramDefault, 64); err == nil {
+			return v
+		}
+	case "boolean":
+		if v, err := strconv.ParseBool(n.paramDefault); err == nil {
+			return v
+		}
+	}
+	return n.paramDefault
+}
+
+func (n *node) removeLeaf(l *Leaf) {
+	head, ok := n.leaves[l.method]
+	if !ok {
+		return
+	}
+	if head == l {
+		if l.next == nil {
+			delete(n.leaves, l.method)
+		} else {
+			n.leaves[l.method] = l.next
+		}
+		return
+	}
+	for prev := head; prev.next != nil; prev = prev.next {
+		if prev.next == l {
+			prev.next = l.next
+			return
+		}
+	}
+}
+
+// removeRoute removes leaves of method added with pattern, aliases of optional params
+// included, and prunes nodes left empty.
+func (n *node) removeRoute(method Method, pattern string) error {
+	if len(pattern) > 1 && pattern[len(pattern)-1] == '/' {
+		pattern = pattern[:len(pattern)-1]
+	}
+	patterns := []string{pattern}
+	for _, r := range expandOptional(pattern) {
+		patterns = append(patterns, r.pattern)
+	}
+	for i, p := range patterns {
+		target := n.find(p)
+		if target == nil || target.leaves[method] == nil {
+			if i == 0 {
+				return &RouteError{Method: method, Pattern: pattern, Reason: "route not found"}
+			}
+			continue
+		}
+		delete(target.leaves, method)
+		target.prune()
+	}
+	return nil
+}
+
+// find returns the node pattern was added into, or nil if there isn't one.
+func (n *node) find(pattern string) *node {
+	if n.isParam() {
+		i := strings.IndexByte(pattern, '}')
+		if i < 0 || n.segment != pattern[:i+1] {
+			return nil
+		}
+		return n.findChild(pattern[i+1:])
+	}
+	if !strings.HasPrefix(pattern, n.segment) {
+		return nil
+	}
+	return n.findChild(pattern[len(n.segment):])
+}
+
+func (n *node) findChild(pattern string) *node {
+	if len(pattern) == 0 {
+		return n
+	}
+	if pattern[0] == '{' {
+		segment := pattern[:strings.IndexByte(pattern, '}')+1]
+		for _, ch := range n.paramChildren {
+			if ch.segment == segment {
+				return ch.find(pattern)
+			}
+		}
+		return nil
+	}
+	if child := n.staticChild(pattern[0]); child != nil {
+		return child.find(pattern)
+	}
+	return nil
+}
+
+// staticChild returns the static child beginning with c, or nil if there isn't one.
+func (n *node) staticChild(c byte) *node {
+	if i := strings.IndexByte(n.indices, c); i >= 0 {
+		return n.children[i]
+	}
+	return nil
+}
+
+// prune removes n and its ancestors from tree as long as they have nothing to serve.
+func (n *node) prune() {
+	for ; n.parent != nil; n = n.parent {
+		if len(n.leaves) > 0 || len(n.children) > 0 || len(n.paramChildren) > 0 {
+			return
+		}
+		parent := n.parent
+		for i, ch := range parent.children {
+			if ch == n {
+				parent.indices = parent.indices[:i] + parent.indices[i+1:]
+				parent.children = append(parent.children[:i:i], parent.children[i+1:]...)
+				break
+			}
+		}
+		for i, ch := range parent.paramChildren {
+			if ch == n {
+				parent.paramChildren = append(parent.paramChildren[:i:i], parent.paramChildren[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// clone returns a deep copy of the subtree with parent, every Leaf copied is
+// recorded in leaves by the original one.
+func (n *node) clone(parent *node, leaves map[*Leaf]*Leaf) *node {
+	c := new(node)
+	*c = *n
+	c.parent = parent
+	c.children = nil
+	for _, ch := range n.children {
+		c.children = append(c.children, ch.clone(c, leaves))
+	}
+	c.paramChildren = nil
+	for _, ch := range n.paramChildren {
+		c.paramChildren = append(c.paramChildren, ch.clone(c, leaves))
+	}
+	if n.leaves == nil {
+		return c
+	}
+	c.leaves = make(map[Method]*Leaf, len(n.leaves))
+	for method, l := range n.leaves {
+		var prev *Leaf
+		for ; l != nil; l = l.next {
+			cl := new(Leaf)
+			*cl = *l
+			cl.parent = c
+			cl.next = nil
+			cl.matchers = append([]Matcher(nil), l.matchers...)
+			cl.filters = append([]Filter(nil), l.filters...)
+			leaves[l] = cl
+			if prev == nil {
+				c.leaves[method] = cl
+			} else {
+				prev.next = cl
+			}
+			prev = cl
+		}
+	}
+	return c
+}
+
+// paramCount returns count of params along the path from root to n.
+func (n *node) paramCount() int {
+	count := 0
+	for ; n != nil; n = n.parent {
+		if n.isParam() {
+			count++
+		}
+	}
+	return count
+}
+
+func (n *node) isParam() bool {
+	return n.ntype == param || n.ntype == matchAll
 }
 
 func (n *node) findMaxParams() int {
 	base := 0
-	if n.ntype == param {
+	if n.isParam() {
 		base = 1
 	}
 	max := 0
@@ -235,36 +555,51 @@ func (n *node) findMaxParams() int {
 			max = submax
 		}
 	}
-	if n.paramChild != nil {
-		if submax := n.paramChild.findMaxParams(); submax > max {
+	for _, ch := range n.paramChildren {
+		if submax := ch.findMaxParams(); submax > max {
 			max = submax
 		}
 	}
 	return max + base
 }
 
+// match matches path regardless of matchers, the first Leaf of method is returned.
 func (n *node) match(method Method, path string, params Params) (Params, *Leaf, error) {
+	return n.matchRequest(nil, method, path, params)
+}
+
+// matchRequest matches path with Leaf whose matchers match req. Routes whose matchers
+// don't match are skipped, so the next candidate is tried by backtracking.
+func (n *node) matchRequest(req *http.Request, method Method, path string, params Params) (Params, *Leaf, error) {
 	switch n.ntype {
 	case static:
-		return n.matchStatic(method, path, params)
+		return n.matchStatic(req, method, path, params)
 	case param:
-		return n.matchParam(method, path, params)
+		return n.matchParam(req, method, path, params)
+	case matchAll:
+		return n.matchParamAt(req, method, path, len(path), params)
 	}
 	return params, nil, err404
 }
 
-func (n *node) matchLeaf(method Method) (*Leaf, error) {
+func (n *node) matchLeaf(req *http.Request, method Method) (*Leaf, error) {
 	if len(n.leaves) == 0 {
 		return nil, err404
 	}
 	// method matches
 	if l, ok := n.leaves[method]; ok {
-		return l, nil
+		if req == nil {
+			return l, nil
+		}
+		if l = l.pick(req); l != nil {
+			return l, nil
+		}
+		return nil, err404
 	}
 	return nil, err405
 }
 
-func (n *node) matchStatic(method Method, path string, params Params) (Params, *Leaf, error) {
+func (n *node) matchStatic(req *http.Request, method Method, path string, params Params) (Params, *Leaf, error) {
 	if len(path) < len(n.segment) {
 		return params, nil, err404
 	}
@@ -276,61 +611,99 @@ func (n *node) matchStatic(method Method, path string, params Params) (Params, *
 		return params, nil, err404
 	}
 	if i == len(path) {
-		l, err := n.matchLeaf(method)
+		l, err := n.matchLeaf(req, method)
 		return params, l, err
 	}
-	c := path[seglen]
-	for index, ind := range n.indices {
-		if ind == rune(c) {
-			return n.children[index].match(method, path[seglen:], params)
-		}
-	}
-	if n.paramChild != nil {
-		return n.paramChild.match(method, path[seglen:], params)
-	}
-	return params, nil, err404
+	return n.matchChildren(req, method, path[seglen:], params)
 }
 
-func (n *node) matchParam(method Method, path string, params Params) (Params, *Leaf, error) {
+func (n *node) matchParam(req *http.Request, method Method, path string, params Params) (Params, *Leaf, error) {
 	i, max := 0, len(path)
 	for i < max && path[i] != '/' {
 		i++
 	}
 
-	if n.paramReg != nil && !n.paramReg.MatchString(path[:i]) {
+	if !n.hasSuffix() {
+		return n.matchParamAt(req, method, path, i, params)
+	}
+	// the param is followed by literal suffixes in the same segment,
+	// try to end it before each of them, shortest first, then the whole segment.
+	var err error = err404
+	for j := 1; j <= i; j++ {
+		if j < i && strings.IndexByte(n.indices, path[j]) < 0 {
+			continue
+		}
+		ps, l, e := n.matchParamAt(req, method, path, j, params)
+		if e == nil {
+			return ps, l, nil
+		}
+		if e != err404 {
+			err = e
+		}
+	}
+	return params, nil, err
+}
+
+// matchParamAt matches path[:end] as value of the param, and the rest by children.
+func (n *node) matchParamAt(req *http.Request, method Method, path string, end int, params Params) (Params, *Leaf, error) {
+	if n.paramReg != nil && !n.paramReg.MatchString(path[:end]) {
+		return params, nil, err404
+	}
+	if n.paramMatcher != nil && !n.paramMatcher.MatchParam(path[:end]) {
 		return params, nil, err404
 	}
 
-	params = params[:len(params)+1]
-	params[len(params)-1].Key = n.paramName
-	params[len(params)-1].Value = path[:i]
+	// params has enough capacity for the deepest route, append never allocates.
+	params = append(params, Param{Key: n.paramName, Value: path[:end]})
 
-	if i == max {
-		l, err := n.matchLeaf(method)
+	if end == len(path) {
+		l, err := n.matchLeaf(req, method)
 		return params, l, err
 	}
-	c := path[i]
-	for index, ind := range n.indices {
-		if ind == rune(c) {
-			return n.children[index].match(method, path[i:], params)
+	return n.matchChildren(req, method, path[end:], params)
+}
+
+// hasSuffix reports whether any static child begins within the same segment.
+func (n *node) hasSuffix() bool {
+	return len(n.indices) > 1 || (len(n.indices) == 1 && n.indices[0] != '/')
+}
+
+// matchChildren tries the static child first, then the param children in order.
+// It backtracks to the next candidate if the previous one doesn't match, and
+// err405 takes precedence over err404 if none matches.
+func (n *node) matchChildren(req *http.Request, method Method, path string, params Params) (Params, *Leaf, error) {
+	var err error = err404
+	if child := n.staticChild(path[0]); child != nil {
+		ps, l, e := child.matchRequest(req, method, path, params)
+		if e == nil {
+			return ps, l, nil
 		}
+		err = e
 	}
-	if n.paramChild != nil {
-		return n.paramChild.match(method, path[:i], params)
+	for _, child := range n.paramChildren {
+		ps, l, e := child.matchRequest(req, method, path, params)
+		if e == nil {
+			return ps, l, nil
+		}
+		if e != err404 {
+			err = e
+		}
 	}
-	return params, nil, err404
+	return params, nil, err
 }
 
 func (n *node) travel(llist *list.List) {
 	for _, l := range n.leaves {
-		llist.PushBack(l)
+		for ; l != nil; l = l.next {
+			llist.PushBack(l)
+		}
 	}
 
 	for _, child := range n.children {
 		child.travel(llist)
 	}
-	if n.paramChild != nil {
-		n.paramChild.travel(llist)
+	for _, child := range n.paramChildren {
+		child.travel(llist)
 	}
 }
 
@@ -338,7 +711,7 @@ func (n *node) path() string {
 	var path string
 	if n.ntype == static {
 		path = n.segment
-	} else if n.ntype == param {
+	} else if n.isParam() {
 		path = "{" + n.paramName + "}"
 	}
 	if n.parent != nil {
@@ -347,23 +720,48 @@ func (n *node) path() string {
 	return path
 }
 
-func (n *node) _travel(path string) {
-	path += n.segment
-	for m, l := range n.leaves {
-		if path != l.path {
-			fmt.Printf("%s %s ===== %s\n", m, path, l.Path())
+// rawPath returns the full pattern from root to this node, with param regexps kept.
+func (n *node) rawPath() string {
+	if n.parent != nil {
+		return n.parent.rawPath() + n.segment
+	}
+	return n.segment
+}
+
+// validate checks the subtree and appends issues found into errs.
+// names contains param names along the path from root.
+func (n *node) validate(names []string, errs RouteErrors) RouteErrors {
+	if n.isParam() {
+		for _, name := range names {
+			if name == n.paramName {
+				errs = append(errs, &RouteError{
+					Pattern: n.rawPath(),
+					Reason:  "duplicate param name " + name,
+				})
+				break
+			}
 		}
+		names = append(names, n.paramName)
 	}
 	for _, child := range n.children {
-		child._travel(path)
-	}
-	if n.paramChild != nil {
-		n.paramChild._travel(path)
+		errs = child.validate(names, errs)
+	}
+	for i, child := range n.paramChildren {
+		for _, prev := range n.paramChildren[:i] {
+			if prev.paramRule() == child.paramRule() && (prev.ntype == matchAll) == (child.ntype == matchAll) {
+				errs = append(errs, &RouteError{
+					Pattern:  child.rawPath(),
+					Conflict: prev.rawPath(),
+					Reason:   "ambiguous param node " + child.paramName,
+				})
+			}
+		}
+		errs = child.validate(names, errs)
 	}
+	return errs
 }
 
 func readParam(pattern string) (name, regstr, dataType, desc, rest string) {
-	dataType = "string"
 	field, rest, end := readField(pattern[1:])
 	name = field
 	if end || len(rest) == 0 {
@@ -377,9 +775,7 @@ func readParam(pattern string) (name, regstr, dataType, desc, rest string) {
 	}
 
 	field, rest, end = readField(rest)
-	if len(field) > 0 {
-		dataType = field
-	}
+	dataType = field
 	if end || len(rest) == 0 {
 		return
 	}
@@ -399,12 +795,165 @@ func readField(pattern string) (field, rest string, end bool) {
 		if pattern[i] == ':' {
 			return pattern[:i], pattern[i+1:], false
 		} else if pattern[i] == '}' {
-			if i < max-1 && pattern[i+1] != '/' {
-				panic("'}' should be before '/' or at the end")
-			}
 			return pattern[:i], pattern[i+1:], true
 		}
 		i++
 	}
-	panic("'}' should be before '/' or at the end")
+	panic("missing '}'")
+}
+
+// checkPattern checks the syntax of pattern before it's inserted into the tree,
+// so that no half-inserted route would be left if it's invalid.
+func checkPattern(pattern string) error {
+	var names []string
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '}':
+			return &RouteError{Reason: "unexpected '}'"}
+		case '{':
+			end := strings.IndexByte(pattern[i:], '}')
+			if end < 0 {
+				return &RouteError{Reason: "missing '}'"}
+			}
+			end += i
+			if end < len(pattern)-1 && pattern[end+1] == '{' {
+				return &RouteError{Reason: "'}' should NOT be followed by '{'"}
+			}
+			fields := strings.SplitN(pattern[i+1:end], ":", 5)
+			if len(fields) > 4 {
+				return &RouteError{Reason: "too many fields in param " + pattern[i:end+1]}
+			}
+			name := fields[0]
+			optional := strings.HasSuffix(name, "?")
+			if optional {
+				name = name[:len(name)-1]
+				if pattern[i-1] != '/' || (end < len(pattern)-1 && pattern[end+1] != '/') {
+					return &RouteError{Reason: "optional param should be a whole segment"}
+				}
+			}
+			if strings.HasSuffix(name, "*") {
+				name = name[:len(name)-1]
+				if pattern[i-1] != '/' || end < len(pattern)-1 {
+					return &RouteError{Reason: "matchAll param should be the last segment"}
+				}
+			}
+			if len(name) == 0 {
+				return &RouteError{Reason: "empty param name"}
+			}
+			for _, prev := range names {
+				if prev == name {
+					return &RouteError{Reason: "duplicate param name " + name}
+				}
+			}
+			names = append(names, name)
+			if len(fields) > 1 && len(fields[1]) > 0 {
+				m, err := compileParamRule(fields[1])
+				if err != nil {
+					return &RouteError{Reason: err.Error()}
+				}
+				if optional && len(fields) > 3 {
+					_, _, def, _ := parseOptional(fields[0], fields[3])
+					if def != "" && !m.MatchParam(def) {
+						return &RouteError{Reason: "default value of " + name + " doesn't match " + fields[1]}
+					}
+				}
+			}
+			i = end
+		}
+	}
+	return nil
+}
+
+// compileParamRule compiles rule constraining param, which is either a registered
+// ParamType or a regexp.
+func compileParamRule(rule string) (ParamMatcher, error) {
+	if _, m, ok, err := parseParamType(rule); ok {
+		return m, err
+	}
+	reg, err := regexp.Compile(anchorRule(rule))
+	if err != nil {
+		return nil, fmt.Errorf("invalid regexp: %s", err)
+	}
+	return ParamMatcherFunc(reg.MatchString), nil
+}
+
+// anchorRule makes regexp rule match the whole value of param.
+func anchorRule(rule string) string {
+	return "^(?:" + rule + ")$"
+}
+
+// parseOptional strips the optional mark '?' from name, and splits the default value
+// from desc in form of "desc=default" if the param is optional.
+func parseOptional(name, desc string) (pname, pdesc, def string, optional bool) {
+	if !strings.HasSuffix(name, "?") {
+		return name, desc, "", false
+	}
+	pname, pdesc = name[:len(name)-1], desc
+	if i := strings.LastIndexByte(desc, '='); i >= 0 {
+		pdesc, def = desc[:i], desc[i+1:]
+	}
+	return pname, pdesc, def, true
+}
+
+type optionalRoute struct {
+	pattern  string
+	defaults Params
+	// rule of the optional param omitted right before the end of pattern, whose
+	// position is taken by the next param.
+	omitted     bool
+	omittedRule string
+	// ambiguous routes keep a param at the position of an omitted one with the same
+	// rule, which conflicts with the route keeping the omitted one.
+	ambiguous bool
+}
+
+// expandOptional returns all patterns derived from pattern by omitting its optional
+// params, along with defaults of the omitted ones. pattern itself is excluded, so are
+// patterns omitting an optional param but keeping the next one with the same rule,
+// e.g. "/{y}" of "/{x?}/{y?}", which is served as "/{x}".
+func expandOptional(pattern string) []optionalRoute {
+	routes := []optionalRoute{{}}
+	last := 0
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '{' {
+			continue
+		}
+		end := i + strings.IndexByte(pattern[i:], '}')
+		name, rule, _, desc, _ := readParam(pattern[i : end+1])
+		name, _, def, optional := parseOptional(name, desc)
+		if optional {
+			adjacent := last > 0 && i-1 == last
+			for j, count := 0, len(routes); j < count; j++ {
+				r := routes[j]
+				without := optionalRoute{pattern: r.pattern + pattern[last:i-1], defaults: r.defaults,
+					omitted: true, omittedRule: rule, ambiguous: r.ambiguous}
+				if adjacent && r.omitted {
+					without.omittedRule = r.omittedRule
+				}
+				if def != "" {
+					without.defaults = append(append(Params{}, r.defaults...), Param{Key: name, Value: def})
+				}
+				if adjacent && r.omitted && r.omittedRule == rule {
+					routes[j].ambiguous = true
+				}
+				routes[j].pattern += pattern[last : end+1]
+				routes[j].omitted = false
+				routes = append(routes, without)
+			}
+			last = end + 1
+		}
+		i = end
+	}
+	var expanded []optionalRoute
+	for _, r := range routes[1:] {
+		if r.ambiguous {
+			continue
+		}
+		r.pattern += pattern[last:]
+		if r.pattern == "" {
+			r.pattern = "/"
+		}
+		expanded = append(expanded, r)
+	}
+	return expanded
 }
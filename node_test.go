@@ -65,31 +65,31 @@ func TestNode(t *testing.T) {
 					n.AddRoute(GET, "/{name}", func(*Context) {})
 					convey.So(n.ntype, convey.ShouldEqual, static)
 					convey.So(n.segment, convey.ShouldEqual, "/")
-					convey.So(n.paramChild, convey.ShouldNotBeNil)
-					convey.So(n.paramChild.ntype, convey.ShouldEqual, param)
-					convey.So(n.paramChild.segment, convey.ShouldEqual, "{name}")
+					convey.So(len(n.paramChildren), convey.ShouldEqual, 1)
+					convey.So(n.paramChildren[0].ntype, convey.ShouldEqual, param)
+					convey.So(n.paramChildren[0].segment, convey.ShouldEqual, "{name}")
 				})
 				convey.Convey("param not at root", func() {
 					n := &node{}
 					n.AddRoute(GET, "/foo/{name}", func(*Context) {})
 					convey.So(n.ntype, convey.ShouldEqual, static)
 					convey.So(n.segment, convey.ShouldEqual, "/foo/")
-					convey.So(n.paramChild, convey.ShouldNotBeNil)
-					convey.So(n.paramChild.ntype, convey.ShouldEqual, param)
-					convey.So(n.paramChild.segment, convey.ShouldEqual, "{name}")
+					convey.So(len(n.paramChildren), convey.ShouldEqual, 1)
+					convey.So(n.paramChildren[0].ntype, convey.ShouldEqual, param)
+					convey.So(n.paramChildren[0].segment, convey.ShouldEqual, "{name}")
 				})
 				convey.Convey("param between statics", func() {
 					n := &node{}
 					n.AddRoute(GET, "/foo/{name}/bar", func(*Context) {})
 					convey.So(n.ntype, convey.ShouldEqual, static)
 					convey.So(n.segment, convey.ShouldEqual, "/foo/")
-					convey.So(n.paramChild, convey.ShouldNotBeNil)
-					convey.So(n.paramChild.ntype, convey.ShouldEqual, param)
-					convey.So(n.paramChild.segment, convey.ShouldEqual, "{name}")
-					convey.So(n.paramChild.indices, convey.ShouldEqual, "/")
-					convey.So(len(n.paramChild.children), convey.ShouldEqual, 1)
-					convey.So(n.paramChild.children[0].ntype, convey.ShouldEqual, static)
-					convey.So(n.paramChild.children[0].segment, convey.ShouldEqual, "/bar")
+					convey.So(len(n.paramChildren), convey.ShouldEqual, 1)
+					convey.So(n.paramChildren[0].ntype, convey.ShouldEqual, param)
+					convey.So(n.paramChildren[0].segment, convey.ShouldEqual, "{name}")
+					convey.So(n.paramChildren[0].indices, convey.ShouldEqual, "/")
+					convey.So(len(n.paramChildren[0].children), convey.ShouldEqual, 1)
+					convey.So(n.paramChildren[0].children[0].ntype, convey.ShouldEqual, static)
+					convey.So(n.paramChildren[0].children[0].segment, convey.ShouldEqual, "/bar")
 				})
 				convey.Convey("multi param nodes", func() {
 					n := &node{}
@@ -97,16 +97,16 @@ func TestNode(t *testing.T) {
 					n.AddRoute(GET, "/foo/{name}/fizz", func(*Context) {})
 					convey.So(n.ntype, convey.ShouldEqual, static)
 					convey.So(n.segment, convey.ShouldEqual, "/foo/")
-					convey.So(n.paramChild, convey.ShouldNotBeNil)
-					convey.So(n.paramChild.ntype, convey.ShouldEqual, param)
-					convey.So(n.paramChild.segment, convey.ShouldEqual, "{name}")
-					convey.So(n.paramChild.indices, convey.ShouldEqual, "/")
-					convey.So(len(n.paramChild.children), convey.ShouldEqual, 1)
-					convey.So(n.paramChild.children[0].ntype, convey.ShouldEqual, static)
-					convey.So(n.paramChild.children[0].segment, convey.ShouldEqual, "/")
-					convey.So(n.paramChild.children[0].indices, convey.ShouldEqual, "bf")
-					convey.So(n.paramChild.children[0].children[0].segment, convey.ShouldEqual, "bar")
-					convey.So(n.paramChild.children[0].children[1].segment, convey.ShouldEqual, "fizz")
+					convey.So(len(n.paramChildren), convey.ShouldEqual, 1)
+					convey.So(n.paramChildren[0].ntype, convey.ShouldEqual, param)
+					convey.So(n.paramChildren[0].segment, convey.ShouldEqual, "{name}")
+					convey.So(n.paramChildren[0].indices, convey.ShouldEqual, "/")
+					convey.So(len(n.paramChildren[0].children), convey.ShouldEqual, 1)
+					convey.So(n.paramChildren[0].children[0].ntype, convey.ShouldEqual, static)
+					convey.So(n.paramChildren[0].children[0].segment, convey.ShouldEqual, "/")
+					convey.So(n.paramChildren[0].children[0].indices, convey.ShouldEqual, "bf")
+					convey.So(n.paramChildren[0].children[0].children[0].segment, convey.ShouldEqual, "bar")
+					convey.So(n.paramChildren[0].children[0].children[1].segment, convey.ShouldEqual, "fizz")
 				})
 				convey.Convey("Test fuck", func() {
 					convey.Convey("test users", func() {
@@ -117,8 +117,8 @@ func TestNode(t *testing.T) {
 						n.AddRoute(POST, "/hello/{name}/today", func(*Context) {})
 						convey.So(n.ntype, convey.ShouldEqual, static)
 						convey.So(n.segment, convey.ShouldEqual, "/hello/")
-						convey.So(n.paramChild, convey.ShouldNotBeNil)
-						child := n.paramChild
+						convey.So(len(n.paramChildren), convey.ShouldEqual, 1)
+						child := n.paramChildren[0]
 						convey.So(child.ntype, convey.ShouldEqual, param)
 						convey.So(child.segment, convey.ShouldEqual, "{name}")
 						convey.So(child.ntype, convey.ShouldEqual, param)
@@ -134,6 +134,49 @@ func TestNode(t *testing.T) {
 			})
 		})
 
+		convey.Convey("Test conflicts", func() {
+			convey.Convey("ambiguous param node", func() {
+				n := &node{}
+				n.AddRoute(GET, "/users/{id:\\d+}", func(*Context) {})
+				_, err := n.tryAddRoute(GET, "/users/{uid:\\d+}", func(*Context) {})
+				convey.So(err, convey.ShouldNotBeNil)
+				e := err.(*RouteError)
+				convey.So(e.Method, convey.ShouldEqual, GET)
+				convey.So(e.Pattern, convey.ShouldEqual, "/users/{uid:\\d+}")
+				convey.So(e.Conflict, convey.ShouldEqual, "/users/{id:\\d+}")
+			})
+			convey.Convey("route has been registered", func() {
+				n := &node{}
+				n.AddRoute(GET, "/users/{id}", func(*Context) {})
+				_, err := n.tryAddRoute(GET, "/users/{id}/", func(*Context) {})
+				convey.So(err, convey.ShouldNotBeNil)
+				convey.So(err.(*RouteError).Conflict, convey.ShouldEqual, "/users/{id}")
+				convey.So(func() { n.AddRoute(GET, "/users/{id}", func(*Context) {}) }, convey.ShouldPanic)
+			})
+			convey.Convey("invalid patterns", func() {
+				n := &node{}
+				for _, pattern := range []string{
+					"users",
+					"/users/{id",
+					"/users/{id}{name}",
+					"/users/}",
+					"/users/{}",
+					"/users/{id:[}",
+					"/users/{id}/{id}",
+					"/users/{id:a:b:c:d}",
+					"/users/v{id?}",
+					"/users/{id?}.json",
+					"/users/{id?:\\d+:integer:user id=me}",
+					"/users/{id?:\\d+:integer:user id=1x}",
+				} {
+					_, err := n.tryAddRoute(GET, pattern, func(*Context) {})
+					convey.So(err, convey.ShouldNotBeNil)
+				}
+				convey.So(n.segment, convey.ShouldEqual, "")
+				convey.So(n.children, convey.ShouldBeNil)
+			})
+		})
+
 		convey.Convey("Test match", func() {
 			convey.Convey("single", func() {
 				n := &node{}
@@ -159,6 +202,201 @@ func TestNode(t *testing.T) {
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(lr, convey.ShouldEqual, l)
 			})
+			convey.Convey("alternative params", func() {
+				n := &node{}
+				lname := n.AddRoute(GET, "/users/{name}", func(_ *Context) {})
+				lid := n.AddRoute(GET, "/users/{id:\\d+}", func(_ *Context) {})
+				lslug := n.AddRoute(GET, "/users/{slug:[a-z-]+}/posts", func(_ *Context) {})
+				convey.So(len(n.paramChildren), convey.ShouldEqual, 3)
+				convey.So(n.paramChildren[2].paramName, convey.ShouldEqual, "name")
+
+				params := make(Params, n.findMaxParams())
+				ps, lr, err := n.match(GET, "/users/123", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, lid)
+				convey.So(ps[0].Key, convey.ShouldEqual, "id")
+
+				ps, lr, err = n.match(GET, "/users/jack-d/posts", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, lslug)
+				convey.So(ps[0].Key, convey.ShouldEqual, "slug")
+
+				// backtracking from slug
+				ps, lr, err = n.match(GET, "/users/jack-d", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, lname)
+				convey.So(len(ps), convey.ShouldEqual, 1)
+				convey.So(ps[0].Key, convey.ShouldEqual, "name")
+
+				// rules match the whole param
+				_, lr, err = n.match(GET, "/users/a1", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, lname)
+				_, lr, err = n.match(GET, "/users/1a", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, lname)
+				_, _, err = n.match(GET, "/users/A-b/posts", params[0:0])
+				convey.So(err, convey.ShouldEqual, err404)
+				_, _, err = n.match(GET, "/users/a1/posts", params[0:0])
+				convey.So(err, convey.ShouldEqual, err404)
+
+				_, _, err = n.match(POST, "/users/123", params[0:0])
+				convey.So(err, convey.ShouldEqual, err405)
+				_, _, err = n.match(GET, "/users/123/posts", params[0:0])
+				convey.So(err, convey.ShouldEqual, err404)
+			})
+			convey.Convey("params within segment", func() {
+				n := &node{}
+				lfile := n.AddRoute(GET, "/files/{name}.{ext}", func(_ *Context) {})
+				lgz := n.AddRoute(GET, "/files/{name}.{ext}.gz", func(_ *Context) {})
+				lversion := n.AddRoute(GET, "/v{version:\\d+}/items", func(_ *Context) {})
+				lvalues := n.AddRoute(GET, "/values", func(_ *Context) {})
+				luser := n.AddRoute(GET, "/@{user}", func(_ *Context) {})
+				params := make(Params, n.findMaxParams())
+				convey.So(len(params), convey.ShouldEqual, 2)
+
+				ps, lr, err := n.match(GET, "/files/report.tar.xz", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, lfile)
+				convey.So(ps.GetStringMust("name", ""), convey.ShouldEqual, "report")
+				convey.So(ps.GetStringMust("ext", ""), convey.ShouldEqual, "tar.xz")
+
+				ps, lr, err = n.match(GET, "/files/report.tar.gz", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, lgz)
+				convey.So(ps.GetStringMust("name", ""), convey.ShouldEqual, "report")
+				convey.So(ps.GetStringMust("ext", ""), convey.ShouldEqual, "tar")
+
+				_, _, err = n.match(GET, "/files/report", params[0:0])
+				convey.So(err, convey.ShouldEqual, err404)
+				_, _, err = n.match(GET, "/files/.txt", params[0:0])
+				convey.So(err, convey.ShouldEqual, err404)
+
+				ps, lr, err = n.match(GET, "/v2/items", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, lversion)
+				convey.So(ps.GetStringMust("version", ""), convey.ShouldEqual, "2")
+
+				_, lr, err = n.match(GET, "/values", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, lvalues)
+				_, _, err = n.match(GET, "/vx/items", params[0:0])
+				convey.So(err, convey.ShouldEqual, err404)
+				_, _, err = n.match(GET, "/v2x/items", params[0:0])
+				convey.So(err, convey.ShouldEqual, err404)
+
+				ps, lr, err = n.match(GET, "/@jack", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, luser)
+				convey.So(ps.GetStringMust("user", ""), convey.ShouldEqual, "jack")
+
+				convey.So(lfile.Path(), convey.ShouldEqual, "/files/{name}.{ext}")
+			})
+			convey.Convey("optional params", func() {
+				n := &node{}
+				l := n.AddRoute(GET, "/items/{page?:^\\d+$:integer:page=1}", func(_ *Context) {})
+				lsort := n.AddRoute(GET, "/users/{sort?:^(asc|desc)$}/{page?}/list", func(_ *Context) {})
+				params := make(Params, n.findMaxParams())
+
+				ps, lr, err := n.match(GET, "/items/2", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, l)
+				convey.So(ps.GetStringMust("page", ""), convey.ShouldEqual, "2")
+				convey.So(l.Path(), convey.ShouldEqual, "/items/{page}")
+
+				_, lr, err = n.match(GET, "/items", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldNotEqual, l)
+				convey.So(lr.DocIgnored, convey.ShouldBeTrue)
+
+				for _, path := range []string{
+					"/users/asc/3/list",
+					"/users/desc/list",
+					"/users/3/list",
+					"/users/list",
+				} {
+					_, lr, err = n.match(GET, path, params[0:0])
+					convey.So(err, convey.ShouldBeNil)
+					convey.So(lr == lsort || lr.DocIgnored, convey.ShouldBeTrue)
+				}
+
+				param := l.parent
+				convey.So(param.paramName, convey.ShouldEqual, "page")
+				convey.So(param.paramOptional, convey.ShouldBeTrue)
+				convey.So(param.paramDesc, convey.ShouldEqual, "page")
+				convey.So(param.paramDefaultValue(), convey.ShouldEqual, 1)
+			})
+			convey.Convey("adjacent optional params", func() {
+				n := &node{}
+				la := n.AddRoute(GET, "/a/{x?}/{y?}", func(_ *Context) {})
+				lb := n.AddRoute(GET, "/b/{x?:int}/{y?:int}", func(_ *Context) {})
+				params := make(Params, n.findMaxParams())
+
+				ps, lr, err := n.match(GET, "/a/1/2", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, la)
+				convey.So(ps.GetStringMust("y", ""), convey.ShouldEqual, "2")
+				ps, lr, err = n.match(GET, "/a/1", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr.primary, convey.ShouldEqual, la)
+				convey.So(ps.GetStringMust("x", ""), convey.ShouldEqual, "1")
+				_, lr, err = n.match(GET, "/a", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr.primary, convey.ShouldEqual, la)
+
+				ps, lr, err = n.match(GET, "/b/3", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr.primary, convey.ShouldEqual, lb)
+				convey.So(ps.GetStringMust("x", ""), convey.ShouldEqual, "3")
+
+				convey.So(n.removeRoute(GET, "/a/{x?}/{y?}"), convey.ShouldBeNil)
+				_, _, err = n.match(GET, "/a", params[0:0])
+				convey.So(err, convey.ShouldEqual, err404)
+			})
+			convey.Convey("optional params rollback", func() {
+				n := &node{}
+				n.AddRoute(GET, "/items", func(_ *Context) {})
+				_, err := n.tryAddRoute(GET, "/items/{page?}", func(_ *Context) {})
+				convey.So(err, convey.ShouldNotBeNil)
+				convey.So(err.(*RouteError).Conflict, convey.ShouldEqual, "/items")
+				convey.So(n.findMaxParams(), convey.ShouldEqual, 0)
+				params := make(Params, n.findMaxParams())
+				_, _, err = n.match(GET, "/items/2", params[0:0])
+				convey.So(err, convey.ShouldEqual, err404)
+			})
+			convey.Convey("matchAll params", func() {
+				n := &node{}
+				lall := n.AddRoute(GET, "/static/{filepath*}", func(_ *Context) {})
+				lcss := n.AddRoute(GET, "/static/{file:.+\\.css}", func(_ *Context) {})
+				convey.So(len(n.paramChildren), convey.ShouldEqual, 2)
+				convey.So(n.paramChildren[1].ntype, convey.ShouldEqual, matchAll)
+				params := make(Params, n.findMaxParams())
+
+				ps, lr, err := n.match(GET, "/static/js/app.js", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, lall)
+				convey.So(ps.GetStringMust("filepath", ""), convey.ShouldEqual, "js/app.js")
+				convey.So(lall.Path(), convey.ShouldEqual, "/static/{filepath}")
+
+				_, lr, err = n.match(GET, "/static/main.css", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, lcss)
+
+				_, err = n.tryAddRoute(GET, "/static/{filepath*}/more", func(_ *Context) {})
+				convey.So(err, convey.ShouldNotBeNil)
+			})
+			convey.Convey("backtracking from static to param", func() {
+				n := &node{}
+				lstatic := n.AddRoute(GET, "/users/me/profile", func(_ *Context) {})
+				lparam := n.AddRoute(GET, "/users/{name}", func(_ *Context) {})
+				params := make(Params, n.findMaxParams())
+				_, lr, err := n.match(GET, "/users/me/profile", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, lstatic)
+				_, lr, err = n.match(GET, "/users/mary", params[0:0])
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lr, convey.ShouldEqual, lparam)
+			})
 			convey.Convey("param with regexp", func() {
 				n := &node{}
 				l := n.AddRoute(GET, "/hello/{name:\\d+}", func(_ *Context) {})
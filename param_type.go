@@ -0,0 +1,233 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Xuyuanp/hador/swagger"
+)
+
+// ParamMatcher checks values of path params.
+type ParamMatcher interface {
+	MatchParam(value string) bool
+}
+
+// ParamMatcherFunc as function
+type ParamMatcherFunc func(value string) bool
+
+// MatchParam implements ParamMatcher interface
+func (f ParamMatcherFunc) MatchParam(value string) bool {
+	return f(value)
+}
+
+// ParamDocumenter is implemented by ParamMatcher which documents its constraints
+// into swagger, e.g. minimum and maximum of ranges.
+type ParamDocumenter interface {
+	DocItems(items *swagger.Items)
+}
+
+// ParamType is a named constraint of path params, which is used in place of regexp
+// in patterns, e.g. "{id:int}" or "{page:int(1,100)}".
+type ParamType struct {
+	// New creates ParamMatcher with args in parens, args is nil if there is none.
+	New func(args []string) (ParamMatcher, error)
+	// DataType and Format are documented in swagger. DataType is used as the data
+	// type of param if it's absent in pattern.
+	DataType string
+	Format   string
+}
+
+var paramTypes = map[string]ParamType{
+	"int":   {New: newIntMatcher(true), DataType: "integer", Format: "int64"},
+	"uint":  {New: newIntMatcher(false), DataType: "integer", Format: "int64"},
+	"uuid":  {New: simpleParamMatcher(isUUID), DataType: "string", Format: "uuid"},
+	"alpha": {New: simpleParamMatcher(isAlpha), DataType: "string"},
+	"slug":  {New: simpleParamMatcher(isSlug), DataType: "string"},
+	"date":  {New: simpleParamMatcher(isDate), DataType: "string", Format: "date"},
+}
+
+// RegisterParamType registers ParamType with name, which replaces the registered one
+// with the same name, built-in ones included. It's not safe for concurrent use, and
+// routes using name should be added after registered.
+func RegisterParamType(name string, pt ParamType) {
+	if pt.New == nil {
+		panic("ParamType.New shouldn't be nil")
+	}
+	if pt.DataType == "" {
+		pt.DataType = "string"
+	}
+	paramTypes[name] = pt
+}
+
+// parseParamType parses rule in form of "name" or "name(arg1,arg2)".
+// ok is false if name isn't a registered ParamType.
+func parseParamType(rule string) (pt ParamType, m ParamMatcher, ok bool, err error) {
+	name, args := rule, []string(nil)
+	if i := strings.IndexByte(rule, '('); i >= 0 && strings.HasSuffix(rule, ")") {
+		name = rule[:i]
+		if argstr := strings.TrimSpace(rule[i+1 : len(rule)-1]); argstr != "" {
+			args = strings.Split(argstr, ",")
+			for j := range args {
+				args[j] = strings.TrimSpace(args[j])
+			}
+		}
+	}
+	if pt, ok = paramTypes[name]; !ok {
+		return
+	}
+	m, err = pt.New(args)
+	if err != nil {
+		err = fmt.Errorf("invalid param type %s: %s", rule, err)
+	}
+	return
+}
+
+func simpleParamMatcher(f func(string) bool) func([]string) (ParamMatcher, error) {
+	return func(args []string) (ParamMatcher, error) {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("no args expected")
+		}
+		return ParamMatcherFunc(f), nil
+	}
+}
+
+// intMatcher matches decimal integers, in range [min, max] if ranged.
+type intMatcher struct {
+	signed   bool
+	ranged   bool
+	min, max int64
+}
+
+func newIntMatcher(signed bool) func([]string) (ParamMatcher, error) {
+	return func(args []string) (ParamMatcher, error) {
+		m := &intMatcher{signed: signed}
+		if len(args) == 0 {
+			return m, nil
+		}
+		if len(args) != 2 {
+			return nil, fmt.Errorf("range expects 2 args, got %d", len(args))
+		}
+		var err error
+		if m.min, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+			return nil, err
+		}
+		if m.max, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return nil, err
+		}
+		if m.min > m.max {
+			return nil, fmt.Errorf("min %d is greater than max %d", m.min, m.max)
+		}
+		m.ranged = true
+		return m, nil
+	}
+}
+
+func (m *intMatcher) MatchParam(value string) bool {
+	digits := value
+	if m.signed && len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 {
+		return false
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return false
+		}
+	}
+	if !m.ranged {
+		return true
+	}
+	v, err := strconv.ParseInt(value, 10, 64)
+	return err == nil && v >= m.min && v <= m.max
+}
+
+func (m *intMatcher) DocItems(items *swagger.Items) {
+	if m.ranged {
+		items.Minimum = int(m.min)
+		items.Maximum = int(m.max)
+	}
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHex(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isAlpha(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// isSlug reports whether s consists of lowercase letters and digits, separated by
+// single hyphens, e.g. "hello-world-2".
+func isSlug(s string) bool {
+	if len(s) == 0 || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '-' {
+			if s[i-1] == '-' {
+				return false
+			}
+			continue
+		}
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDate(s string) bool {
+	if len(s) != len("2006-01-02") {
+		return false
+	}
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Xuyuanp/hador/swagger"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestParamType(t *testing.T) {
+	convey.Convey("Given built-in ParamTypes", t, func() {
+		match := func(rule, value string) bool {
+			m, err := compileParamRule(rule)
+			convey.So(err, convey.ShouldBeNil)
+			return m.MatchParam(value)
+		}
+		convey.So(match("int", "-42"), convey.ShouldBeTrue)
+		convey.So(match("int", "4a"), convey.ShouldBeFalse)
+		convey.So(match("int", "-"), convey.ShouldBeFalse)
+		convey.So(match("uint", "-42"), convey.ShouldBeFalse)
+		convey.So(match("int(1, 100)", "100"), convey.ShouldBeTrue)
+		convey.So(match("int(1,100)", "0"), convey.ShouldBeFalse)
+		convey.So(match("uuid", "123e4567-e89b-12d3-a456-426614174000"), convey.ShouldBeTrue)
+		convey.So(match("uuid", "123e4567-e89b-12d3-a456-42661417400g"), convey.ShouldBeFalse)
+		convey.So(match("alpha", "Hador"), convey.ShouldBeTrue)
+		convey.So(match("alpha", "h4dor"), convey.ShouldBeFalse)
+		convey.So(match("slug", "hello-world-2"), convey.ShouldBeTrue)
+		convey.So(match("slug", "hello--world"), convey.ShouldBeFalse)
+		convey.So(match("slug", "-hello"), convey.ShouldBeFalse)
+		convey.So(match("date", "2016-02-29"), convey.ShouldBeTrue)
+		convey.So(match("date", "2015-02-29"), convey.ShouldBeFalse)
+		convey.So(match("^v\\d$", "v1"), convey.ShouldBeTrue)
+
+		for _, rule := range []string{"int(1)", "int(a,b)", "int(9,1)", "uuid(1)"} {
+			_, err := compileParamRule(rule)
+			convey.So(err, convey.ShouldNotBeNil)
+		}
+	})
+
+	convey.Convey("Given a Hador with typed params", t, func() {
+		RegisterParamType("even", ParamType{
+			New: func(args []string) (ParamMatcher, error) {
+				return ParamMatcherFunc(func(value string) bool {
+					return len(value) > 0 && strings.IndexByte("02468", value[len(value)-1]) >= 0
+				}), nil
+			},
+			DataType: "integer",
+		})
+		h := New()
+		echo := func(ctx *Context) {
+			ctx.WriteString(ctx.Request.URL.Path)
+		}
+		h.Get("/users/{id:uint}", echo)
+		h.Get("/users/{name:alpha}", echo)
+		h.Get("/pages/{page:int(1,100)::page number}", echo)
+		h.Get("/events/{day:date}", echo)
+		h.Get("/numbers/{n:even:string}", echo)
+
+		serve := func(path string) int {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			h.ServeHTTP(resp, req)
+			return resp.Code
+		}
+
+		convey.Convey("match", func() {
+			convey.So(serve("/users/42"), convey.ShouldEqual, http.StatusOK)
+			convey.So(serve("/users/jack"), convey.ShouldEqual, http.StatusOK)
+			convey.So(serve("/users/jack42"), convey.ShouldEqual, http.StatusNotFound)
+			convey.So(serve("/pages/100"), convey.ShouldEqual, http.StatusOK)
+			convey.So(serve("/pages/101"), convey.ShouldEqual, http.StatusNotFound)
+			convey.So(serve("/events/2016-01-02"), convey.ShouldEqual, http.StatusOK)
+			convey.So(serve("/events/today"), convey.ShouldEqual, http.StatusNotFound)
+			convey.So(serve("/numbers/12"), convey.ShouldEqual, http.StatusOK)
+			convey.So(serve("/numbers/13"), convey.ShouldEqual, http.StatusNotFound)
+		})
+		convey.Convey("invalid pattern", func() {
+			err := h.Register(func(r Router) {
+				r.Get("/bad/{id:int(1)}", echo)
+				r.Get("/bad/{page?:int::page=abc}", echo)
+			})
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.(RouteErrors), convey.ShouldHaveLength, 2)
+		})
+		convey.Convey("ambiguous with the same type", func() {
+			err := h.Register(func(r Router) {
+				r.Get("/users/{uid:uint}", echo)
+			})
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+		convey.Convey("document", func() {
+			paths := h.travelPaths()
+			param := func(path, name string) swagger.Parameter {
+				for _, p := range paths[path]["get"].Parameters {
+					if p.Name == name {
+						return p
+					}
+				}
+				return swagger.Parameter{}
+			}
+			convey.So(param("/users/{id}", "id").Type, convey.ShouldEqual, "integer")
+			convey.So(param("/users/{id}", "id").Format, convey.ShouldEqual, "int64")
+			convey.So(param("/pages/{page}", "page").Minimum, convey.ShouldEqual, 1)
+			convey.So(param("/pages/{page}", "page").Maximum, convey.ShouldEqual, 100)
+			convey.So(param("/events/{day}", "day").Format, convey.ShouldEqual, "date")
+			convey.So(param("/numbers/{n}", "n").Type, convey.ShouldEqual, "string")
+		})
+	})
+}
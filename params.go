@@ -18,13 +18,69 @@ package hador
 
 import (
 	"fmt"
+	"net/url"
 	"strconv"
+	"strings"
 )
 
 // Param is Params' entry
 type Param struct {
 	Key   string
 	Value string
+	// Raw is Value with '/' and '%' escaped if routed with Hador.UseRawPath, empty otherwise.
+	Raw string
+}
+
+// routingPath unescapes the escaped path except encoded '/' and '%', which are
+// unescaped in params by unescapeParams after routing.
+func routingPath(escaped string) string {
+	if strings.IndexByte(escaped, '%') < 0 {
+		return escaped
+	}
+	b := make([]byte, 0, len(escaped))
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] == '%' && i+2 < len(escaped) && ishex(escaped[i+1]) && ishex(escaped[i+2]) {
+			if c := unhex(escaped[i+1])<<4 | unhex(escaped[i+2]); c != '/' && c != '%' {
+				b = append(b, c)
+				i += 2
+				continue
+			}
+		}
+		b = append(b, escaped[i])
+	}
+	return string(b)
+}
+
+func ishex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+// unescapeParams unescapes values of params in place, keeping the escaped ones as Raw.
+// It returns false if any value is malformed.
+func unescapeParams(params Params) bool {
+	for i := range params {
+		raw := params[i].Value
+		params[i].Raw = raw
+		if strings.IndexByte(raw, '%') < 0 {
+			continue
+		}
+		value, err := url.PathUnescape(raw)
+		if err != nil {
+			return false
+		}
+		params[i].Value = value
+	}
+	return true
 }
 
 // Params is a wrapper of map[string]string to handle the params in regexp pattern.
@@ -22,11 +22,22 @@ import (
 	"runtime"
 )
 
-// NewRecoveryFilter return a Filter to recover all unrecovered panic
+// NewRecoveryFilter return a Filter to recover all unrecovered panic. Panics with
+// Error or HTTPError are responded by their statuses, others by 500.
 func NewRecoveryFilter(logger Logger) FilterFunc {
 	return func(ctx *Context, next Handler) {
 		defer func() {
 			if err := recover(); err != nil {
+				// Error and HTTPError are panicked on purpose to abort the request.
+				if e, ok := err.(error); ok {
+					if status, ok := errorStatus(e); ok {
+						if status >= 500 {
+							logger.Error("PANIC: %s", e)
+						}
+						ctx.HandleError(e)
+						return
+					}
+				}
 				trace := make([]byte, 1<<16)
 				n := runtime.Stack(trace, true)
 				stack := trace[:n]
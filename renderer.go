@@ -0,0 +1,131 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// Renderer encodes v into w for Context.Render.
+type Renderer interface {
+	Render(w io.Writer, v interface{}) error
+}
+
+// RendererFunc is a function implements Renderer interface.
+type RendererFunc func(w io.Writer, v interface{}) error
+
+// Render implements Renderer interface
+func (rf RendererFunc) Render(w io.Writer, v interface{}) error {
+	return rf(w, v)
+}
+
+var (
+	// JSONRenderer renders v in JSON format.
+	JSONRenderer = RendererFunc(func(w io.Writer, v interface{}) error {
+		return json.NewEncoder(w).Encode(v)
+	})
+	// XMLRenderer renders v in XML format.
+	XMLRenderer = RendererFunc(func(w io.Writer, v interface{}) error {
+		return xml.NewEncoder(w).Encode(v)
+	})
+)
+
+type rendererEntry struct {
+	contentType string
+	renderer    Renderer
+}
+
+// Renderers is a registry of Renderer by media type, in order of preference.
+type Renderers struct {
+	mediaTypes []string
+	entries    map[string]rendererEntry
+}
+
+// NewRenderers creates new Renderers instance with JSON and XML registered.
+func NewRenderers() *Renderers {
+	rs := &Renderers{entries: make(map[string]rendererEntry)}
+	rs.Register(contentTypeJSON, JSONRenderer)
+	rs.Register(contentTypeXML, XMLRenderer)
+	rs.Register("text/xml; charset=utf-8", XMLRenderer)
+	return rs
+}
+
+var defaultRenderers = NewRenderers()
+
+// Register registers renderer for contentType, which is sent as Content-Type header
+// and matched against Accept header without parameters. Renderers registered earlier
+// are preferred if the client accepts several equally, and re-registering a media
+// type keeps its place.
+func (rs *Renderers) Register(contentType string, renderer Renderer) *Renderers {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		panic(err)
+	}
+	if _, ok := rs.entries[mediaType]; !ok {
+		rs.mediaTypes = append(rs.mediaTypes, mediaType)
+	}
+	rs.entries[mediaType] = rendererEntry{contentType: contentType, renderer: renderer}
+	return rs
+}
+
+// MediaTypes returns registered media types in order of preference.
+func (rs *Renderers) MediaTypes() []string {
+	return append([]string(nil), rs.mediaTypes...)
+}
+
+func (rs *Renderers) negotiate(accept string) (rendererEntry, bool) {
+	entry, ok := rs.entries[negotiate(accept, rs.mediaTypes...)]
+	return entry, ok
+}
+
+// Renderers returns the Renderers used by Context.Render. Renderers should be
+// registered before serving.
+func (h *Hador) Renderers() *Renderers {
+	return h.renderers
+}
+
+// Render renders v by the Renderer negotiated by Accept header and sets status if
+// provided. If nothing is acceptable, 406 is responded by OnError. If v fails to be
+// rendered, the error is returned after handled by HandleError.
+func (ctx *Context) Render(v interface{}, status ...int) error {
+	rs := ctx.renderers
+	if rs == nil {
+		rs = defaultRenderers
+	}
+	ctx.Response.Header().Add("Vary", "Accept")
+	entry, ok := rs.negotiate(ctx.Request.Header.Get("Accept"))
+	if !ok {
+		ctx.OnError(http.StatusNotAcceptable)
+		return err406
+	}
+	var buf bytes.Buffer
+	if err := entry.renderer.Render(&buf, v); err != nil {
+		if !ctx.Response.Written() {
+			ctx.HandleError(err)
+		}
+		return err
+	}
+	ctx.SetHeader("Content-Type", entry.contentType)
+	_, err := ctx.WriteStatus(buf.Bytes(), status...)
+	return err
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type renderedUser struct {
+	ID   string `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+}
+
+func TestRender(t *testing.T) {
+	convey.Convey("Given Hador with a CSV renderer", t, func() {
+		h := New()
+		h.Renderers().Register("text/csv", RendererFunc(func(w io.Writer, v interface{}) error {
+			u, ok := v.(renderedUser)
+			if !ok {
+				return errors.New("unsupported")
+			}
+			cw := csv.NewWriter(w)
+			cw.Write([]string{u.ID, u.Name})
+			cw.Flush()
+			return cw.Error()
+		}))
+		h.Get("/user", func(ctx *Context) {
+			ctx.Render(renderedUser{ID: "1", Name: "jack"}, http.StatusCreated)
+		})
+		serve := func(accept string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/user", nil)
+			if accept != "" {
+				req.Header.Set("Accept", accept)
+			}
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+
+		convey.Convey("JSON is preferred", func() {
+			for _, accept := range []string{"", "*/*", "application/json, text/csv"} {
+				resp := serve(accept)
+				convey.So(resp.Code, convey.ShouldEqual, http.StatusCreated)
+				convey.So(resp.Header().Get("Content-Type"), convey.ShouldEqual, contentTypeJSON)
+				convey.So(resp.Header().Get("Vary"), convey.ShouldEqual, "Accept")
+				convey.So(resp.Body.String(), convey.ShouldEqual, `{"id":"1","name":"jack"}`+"\n")
+			}
+		})
+
+		convey.Convey("q-values are respected", func() {
+			resp := serve("application/json;q=0.5, text/csv")
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldEqual, "text/csv")
+			convey.So(resp.Body.String(), convey.ShouldEqual, "1,jack\n")
+
+			resp = serve("text/*;q=0.9, application/json;q=0.1")
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldEqual, "text/xml; charset=utf-8")
+			convey.So(resp.Body.String(), convey.ShouldEqual, "<renderedUser><id>1</id><name>jack</name></renderedUser>")
+		})
+
+		convey.Convey("406 if nothing matches", func() {
+			resp := serve("image/png")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusNotAcceptable)
+		})
+
+		convey.Convey("500 if rendering fails", func() {
+			h.Get("/chan", func(ctx *Context) {
+				convey.So(ctx.Render(make(chan int)), convey.ShouldNotBeNil)
+			})
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/chan", nil)
+			h.ServeHTTP(resp, req)
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusInternalServerError)
+		})
+
+		convey.Convey("re-registering keeps preference", func() {
+			h.Renderers().Register(contentTypeJSON, JSONRenderer)
+			convey.So(h.Renderers().MediaTypes(), convey.ShouldResemble,
+				[]string{"application/json", "application/xml", "text/xml", "text/csv"})
+			convey.So(func() { h.Renderers().Register("", JSONRenderer) }, convey.ShouldPanic)
+		})
+	})
+}
@@ -112,6 +112,23 @@ func (rw *responseWriter) Before(before func(ResponseWriter)) {
 	rw.beforeFuncs = append(rw.beforeFuncs, before)
 }
 
+func (rw *responseWriter) hijacked(status int) {
+	rw.status = status
+}
+
+// hijackRecorder is implemented by ResponseWriters which record status of
+// the hijacked connection, e.g. 101 of WebSocket, for LogFilter.
+type hijackRecorder interface {
+	hijacked(status int)
+}
+
+// recordHijacked records status into w and the ResponseWriters wrapped by it.
+func recordHijacked(w ResponseWriter, status int) {
+	if r, ok := w.(hijackRecorder); ok {
+		r.hijacked(status)
+	}
+}
+
 func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
 	if !ok {
@@ -120,6 +137,9 @@ func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return hijacker.Hijack()
 }
 
+// CloseNotify implements http.CloseNotifier interface.
+//
+// Deprecated: use Request.Context().Done() instead, like SSE does.
 func (rw *responseWriter) CloseNotify() <-chan bool {
 	return rw.ResponseWriter.(http.CloseNotifier).CloseNotify()
 }
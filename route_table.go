@@ -0,0 +1,181 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import "net/http"
+
+// routeTable is the routing state of Hador. It's replaced as a whole atomically when
+// routes change at runtime, so requests being served never see a half-modified tree.
+type routeTable struct {
+	root  *node
+	hosts []*hostRouter
+	// maxParams is the capacity of Params needed by any route.
+	maxParams int
+	// static indexes leaves of routes without params in root by path, which are
+	// served without walking the tree.
+	static map[string]map[Method]*Leaf
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{root: &node{}, static: make(map[string]map[Method]*Leaf)}
+}
+
+// tryAddRoute adds route into root, and keeps maxParams up to date.
+func (t *routeTable) tryAddRoute(method Method, pattern string, handler interface{}, filters ...Filter) (*Leaf, error) {
+	leaf, err := t.root.tryAddRoute(method, pattern, handler, filters...)
+	if err != nil {
+		return nil, err
+	}
+	// host params are kept when falling back to routes without host.
+	count := leaf.parent.paramCount()
+	hostMax := 0
+	for _, hr := range t.hosts {
+		if c := hr.matcher.findMaxParams(); c > hostMax {
+			hostMax = c
+		}
+	}
+	count += hostMax
+	if count > t.maxParams {
+		t.maxParams = count
+	}
+	if leaf.parent.paramCount() == 0 {
+		// leaves map of node moves along with splitAt, so it's safe to be kept.
+		t.static[leaf.parent.rawPath()] = leaf.parent.leaves
+	}
+	return leaf, nil
+}
+
+// matchStatic returns leaf of the route without params matching path and req, or nil if
+// there isn't one for method, in which case the tree should be walked.
+func (t *routeTable) matchStatic(req *http.Request, method Method, path string) *Leaf {
+	return t.static[path][method].pick(req)
+}
+
+// indexStatic adds leaves of routes without params in subtree n into index.
+func indexStatic(n *node, path string, index map[string]map[Method]*Leaf) map[string]map[Method]*Leaf {
+	path += n.segment
+	if len(n.leaves) > 0 {
+		index[path] = n.leaves
+	}
+	for _, ch := range n.children {
+		indexStatic(ch, path, index)
+	}
+	return index
+}
+
+// register calls fn to add routes without panicking, returns routes failed.
+func (t *routeTable) register(fn func(Router)) RouteErrors {
+	var errs RouteErrors
+	fn(RouterFunc(func(method Method, pattern string, handler interface{}, filters ...Filter) *Leaf {
+		leaf, err := t.tryAddRoute(method, pattern, handler, filters...)
+		if err != nil {
+			errs = append(errs, err.(*RouteError))
+			return newDetachedLeaf(method, pattern)
+		}
+		return leaf
+	}))
+	return errs
+}
+
+func (t *routeTable) findMaxParams() int {
+	rootMax := t.root.findMaxParams()
+	max := rootMax
+	for _, hr := range t.hosts {
+		if submax := hr.maxParams(rootMax); submax > max {
+			max = submax
+		}
+	}
+	return max
+}
+
+// clone returns a deep copy of the table, leaves are copied as well and
+// share FilterChain and swagger Operation with the original ones.
+func (t *routeTable) clone() *routeTable {
+	leaves := make(map[*Leaf]*Leaf)
+	c := &routeTable{
+		root:      t.root.clone(nil, leaves),
+		hosts:     make([]*hostRouter, len(t.hosts)),
+		maxParams: t.maxParams,
+	}
+	c.static = indexStatic(c.root, "", make(map[string]map[Method]*Leaf))
+	for i, hr := range t.hosts {
+		chr := *hr
+		chr.root = hr.root.clone(nil, leaves)
+		c.hosts[i] = &chr
+	}
+	for _, l := range leaves {
+		if l.primary != nil {
+			l.primary = leaves[l.primary]
+		}
+	}
+	return c
+}
+
+func (h *Hador) routes() *routeTable {
+	return h.table.Load().(*routeTable)
+}
+
+// updateRoutes builds a new routing table from the current one by build, and swaps it in.
+func (h *Hador) updateRoutes(build func(cur *routeTable) (*routeTable, error)) error {
+	h.tableMu.Lock()
+	defer h.tableMu.Unlock()
+
+	t, err := build(h.routes())
+	if err != nil {
+		return err
+	}
+	t.maxParams = t.findMaxParams()
+	h.table.Store(t)
+	return nil
+}
+
+// UpdateRoutes calls fn to add routes into a copy of the routing table, and then swaps
+// the copy in atomically, so it's safe to be called while serving. If any route fails
+// to be added, nothing changes and RouteErrors is returned.
+//
+// Every Leaf is copied as well, so leaves returned before are left in the old table,
+// and setting them up afterwards, e.g. Name or Match, has no effect. Leaves returned
+// by the Router passed to fn belong to the new table.
+func (h *Hador) UpdateRoutes(fn func(Router)) error {
+	return h.updateRoutes(func(cur *routeTable) (*routeTable, error) {
+		t := cur.clone()
+		return t, t.register(fn).err()
+	})
+}
+
+// ReplaceRoutes does the same work as UpdateRoutes, but builds the routing table from
+// scratch instead of a copy of the current one. Routes added by Host are kept.
+// Like UpdateRoutes, leaves returned before have no effect after the swap.
+func (h *Hador) ReplaceRoutes(fn func(Router)) error {
+	return h.updateRoutes(func(cur *routeTable) (*routeTable, error) {
+		t := cur.clone()
+		t.root = &node{}
+		t.static = make(map[string]map[Method]*Leaf)
+		return t, t.register(fn).err()
+	})
+}
+
+// RemoveRoute removes routes of method registered with pattern, all alternative leaves
+// with matchers included. Like UpdateRoutes, it's safe to be called while serving,
+// and leaves returned before have no effect after the swap.
+func (h *Hador) RemoveRoute(method Method, pattern string) error {
+	return h.updateRoutes(func(cur *routeTable) (*routeTable, error) {
+		t := cur.clone()
+		return t, t.root.removeRoute(method, pattern)
+	})
+}
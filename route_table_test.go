@@ -0,0 +1,141 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRouteTable(t *testing.T) {
+	convey.Convey("Given a Hador with routes", t, func() {
+		h := New()
+		h.Get("/users", newSimpleHandler("users"))
+		h.Get("/users/{id}", newSimpleHandler("user"))
+		h.Delete("/users/{id}", newSimpleHandler("delete"))
+		h.Get("/pages/{name?:::page name=index}", func(ctx *Context) {
+			ctx.WriteString(ctx.Params().GetStringMust("name", ""))
+		})
+
+		serve := func(method, path string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, path, nil)
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+
+		convey.Convey("remove route", func() {
+			convey.So(h.RemoveRoute("GET", "/users/{id}/"), convey.ShouldBeNil)
+			convey.So(serve("GET", "/users/1").Code, convey.ShouldEqual, http.StatusMethodNotAllowed)
+			convey.So(serve("DELETE", "/users/1").Body.String(), convey.ShouldEqual, "delete")
+			convey.So(serve("GET", "/users").Body.String(), convey.ShouldEqual, "users")
+
+			convey.So(h.RemoveRoute("DELETE", "/users/{id}"), convey.ShouldBeNil)
+			convey.So(serve("DELETE", "/users/1").Code, convey.ShouldEqual, http.StatusNotFound)
+			convey.So(h.routes().root.find("/users/{id}"), convey.ShouldBeNil)
+		})
+		convey.Convey("remove route with optional params", func() {
+			convey.So(h.RemoveRoute("GET", "/pages/{name?:::page name=index}"), convey.ShouldBeNil)
+			convey.So(serve("GET", "/pages").Code, convey.ShouldEqual, http.StatusNotFound)
+			convey.So(serve("GET", "/pages/about").Code, convey.ShouldEqual, http.StatusNotFound)
+		})
+		convey.Convey("remove route not found", func() {
+			err := h.RemoveRoute("POST", "/users")
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.(*RouteError).Reason, convey.ShouldEqual, "route not found")
+			convey.So(h.RemoveRoute("GET", "/posts"), convey.ShouldNotBeNil)
+		})
+		convey.Convey("the old table is untouched", func() {
+			old := h.routes()
+			convey.So(h.RemoveRoute("GET", "/users"), convey.ShouldBeNil)
+			convey.So(h.routes(), convey.ShouldNotEqual, old)
+			_, leaf, err := old.root.match("GET", "/users", nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(leaf.parent.paramCount(), convey.ShouldEqual, 0)
+		})
+		convey.Convey("update routes", func() {
+			err := h.UpdateRoutes(func(r Router) {
+				r.Get("/a/{b}/{c}/{d}/{e}", func(ctx *Context) {
+					ctx.WriteString(ctx.Params().GetStringMust("e", ""))
+				})
+			})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(h.routes().maxParams, convey.ShouldEqual, 4)
+			convey.So(serve("GET", "/a/1/2/3/4").Body.String(), convey.ShouldEqual, "4")
+			convey.So(serve("GET", "/users/1").Body.String(), convey.ShouldEqual, "user")
+
+			old := h.routes()
+			err = h.UpdateRoutes(func(r Router) {
+				r.Get("/posts", newSimpleHandler("posts"))
+				r.Get("/users", newSimpleHandler("users"))
+			})
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(h.routes(), convey.ShouldEqual, old)
+			convey.So(serve("GET", "/posts").Code, convey.ShouldEqual, http.StatusNotFound)
+		})
+		convey.Convey("replace routes", func() {
+			err := h.ReplaceRoutes(func(r Router) {
+				r.Get("/posts", newSimpleHandler("posts"))
+			})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(serve("GET", "/posts").Body.String(), convey.ShouldEqual, "posts")
+			convey.So(serve("GET", "/users").Code, convey.ShouldEqual, http.StatusNotFound)
+			convey.So(h.routes().maxParams, convey.ShouldEqual, 0)
+		})
+		convey.Convey("routes added while updating", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				path := "/concurrent/" + strconv.Itoa(i)
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					h.AddRoute(GET, path, newSimpleHandler("get"))
+				}()
+				go func() {
+					defer wg.Done()
+					h.UpdateRoutes(func(r Router) {
+						r.Post(path, newSimpleHandler("post"))
+					})
+				}()
+			}
+			wg.Wait()
+			for i := 0; i < 20; i++ {
+				path := "/concurrent/" + strconv.Itoa(i)
+				convey.So(serve("GET", path).Body.String(), convey.ShouldEqual, "get")
+				convey.So(serve("POST", path).Body.String(), convey.ShouldEqual, "post")
+			}
+		})
+		convey.Convey("clone keeps aliases pointing to copied leaves", func() {
+			t := h.routes().clone()
+			_, alias, err := t.root.match("GET", "/pages", nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(alias.primary, convey.ShouldNotBeNil)
+			convey.So(alias.primary.parent.paramCount(), convey.ShouldEqual, 1)
+			p := alias.primary.parent
+			for p.parent != nil {
+				p = p.parent
+			}
+			convey.So(p, convey.ShouldEqual, t.root)
+		})
+	})
+}
@@ -76,4 +76,10 @@ type Router interface {
 	AddRoute(method Method, pattern string, h interface{}, filters ...Filter) *Leaf
 
 	Group(pattern string, fn func(Router), filters ...Filter)
+
+	// Mount serves all requests under prefix by handler with prefix stripped.
+	Mount(prefix string, handler interface{}, filters ...Filter)
+
+	// WebSocket serves WebSocket connections upgraded from GET requests after filters.
+	WebSocket(pattern string, handler func(*Context, *Conn), filters ...Filter) *Leaf
 }
@@ -0,0 +1,269 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// RouteInfo describes a route served by Hador.
+type RouteInfo struct {
+	Method  Method      `json:"method"`
+	Host    string      `json:"host,omitempty"`
+	Pattern string      `json:"pattern"`
+	Name    string      `json:"name,omitempty"`
+	Params  []ParamInfo `json:"params,omitempty"`
+	// Filters are added to the route or its groups, filters of Hador applied to
+	// all requests aren't included.
+	Filters []string `json:"filters,omitempty"`
+	Handler string   `json:"handler"`
+	Summary string   `json:"summary,omitempty"`
+}
+
+// ParamInfo describes a path param of route.
+type ParamInfo struct {
+	Name     string `json:"name"`
+	Regexp   string `json:"regexp,omitempty"`
+	Type     string `json:"type,omitempty"`
+	DataType string `json:"dataType"`
+	Optional bool   `json:"optional,omitempty"`
+	Default  string `json:"default,omitempty"`
+}
+
+// Routes returns all routes served by Hador, routes of hosts and mounted Hadors included,
+// sorted by host, pattern and method.
+func (h *Hador) Routes() []RouteInfo {
+	t := h.routes()
+	routes := collectRoutes(t.root, "", "", nil)
+	for _, hr := range t.hosts {
+		routes = collectRoutes(hr.root, hr.pattern, "", routes)
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		ri, rj := routes[i], routes[j]
+		if ri.Host != rj.Host {
+			return ri.Host < rj.Host
+		}
+		if ri.Pattern != rj.Pattern {
+			return ri.Pattern < rj.Pattern
+		}
+		return methodIndex(ri.Method) < methodIndex(rj.Method)
+	})
+	return routes
+}
+
+func collectRoutes(root *node, host, prefix string, routes []RouteInfo) []RouteInfo {
+	for _, leaf := range travelLeaves(root) {
+		if leaf.mount != nil {
+			mprefix := prefix + strings.TrimSuffix(leaf.parent.parent.rawPath(), "/")
+			for _, r := range leaf.mount.Routes() {
+				r.Pattern = mprefix + r.Pattern
+				if r.Host == "" {
+					r.Host = host
+				}
+				routes = append(routes, r)
+			}
+		}
+		// leaves of the mount point are replaced by routes of the mounted Hador.
+		if m, ok := leaf.handler.(*mounted); ok && m.app != nil {
+			continue
+		}
+		routes = append(routes, leaf.routeInfo(host, prefix))
+	}
+	return routes
+}
+
+func (l *Leaf) routeInfo(host, prefix string) RouteInfo {
+	info := RouteInfo{
+		Method:  l.method,
+		Host:    host,
+		Pattern: prefix + l.parent.rawPath(),
+		Name:    l.name,
+		Handler: funcName(l.handler),
+	}
+	if l.primary != nil {
+		info.Handler = funcName(l.primary.handler)
+	}
+	for n := l.parent; n != nil; n = n.parent {
+		if n.isParam() {
+			info.Params = append([]ParamInfo{{
+				Name:     n.paramName,
+				Regexp:   n.paramRegstr,
+				Type:     n.paramType,
+				DataType: n.paramDataType,
+				Optional: n.paramOptional,
+				Default:  n.paramDefault,
+			}}, info.Params...)
+		}
+	}
+	for _, f := range l.filters {
+		if f != nil {
+			info.Filters = append(info.Filters, funcName(f))
+		}
+	}
+	if l.operation != nil {
+		info.Summary = l.operation.Summary
+	}
+	return info
+}
+
+func methodIndex(method Method) int {
+	for i, m := range Methods {
+		if m == method {
+			return i
+		}
+	}
+	return len(Methods)
+}
+
+// funcName returns name of function v, or name of its type if v isn't a function.
+func funcName(v interface{}) string {
+	if th, ok := v.(*typedHandler); ok {
+		return funcName(th.fn.Interface())
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Func {
+		if f := runtime.FuncForPC(rv.Pointer()); f != nil {
+			return f.Name()
+		}
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// WriteRoutes writes routes into w as a table.
+func WriteRoutes(w io.Writer, routes []RouteInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATTERN\tNAME\tHANDLER\tFILTERS")
+	for _, r := range routes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			r.Method, r.Host+r.Pattern, r.Name, r.Handler, strings.Join(r.Filters, ","))
+	}
+	return tw.Flush()
+}
+
+// RoutesHandler returns a debug handler serving Routes as JSON, or as a table if
+// query "format" is "text".
+func (h *Hador) RoutesHandler() Handler {
+	return HandlerFunc(func(ctx *Context) {
+		if ctx.Request.URL.Query().Get("format") == "text" {
+			ctx.SetHeader("Content-Type", "text/plain; charset=utf-8")
+			WriteRoutes(ctx.Response, h.Routes())
+			return
+		}
+		ctx.RenderJSON(h.Routes())
+	})
+}
+
+// URLFor returns the URL path of the route named name by Leaf.Name, with params
+// filled by pairs of key and value, which are escaped. Optional params without
+// value are omitted.
+func (h *Hador) URLFor(name string, pairs ...string) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("odd params of route %s", name)
+	}
+	leaves := h.routes().namedLeaves(name)
+	if len(leaves) == 0 {
+		return "", fmt.Errorf("route %s not found", name)
+	}
+	values := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		values[pairs[i]] = pairs[i+1]
+	}
+	// the primary leaf or the alias of optional params with the most params filled
+	var best string
+	var err error
+	count := -1
+	for _, leaf := range leaves {
+		u, e := buildURL(leaf.parent, values)
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		if c := leaf.parent.paramCount(); c > count {
+			best, count = u, c
+		}
+	}
+	if count < 0 {
+		return "", err
+	}
+	return best, nil
+}
+
+// namedLeaves returns the Leaf named name in t and its aliases of optional params.
+func (t *routeTable) namedLeaves(name string) []*Leaf {
+	roots := []*node{t.root}
+	for _, hr := range t.hosts {
+		roots = append(roots, hr.root)
+	}
+	for _, root := range roots {
+		all := travelLeaves(root)
+		for _, leaf := range all {
+			if leaf.name != name || leaf.primary != nil {
+				continue
+			}
+			leaves := []*Leaf{leaf}
+			for _, alias := range all {
+				if alias.primary == leaf {
+					leaves = append(leaves, alias)
+				}
+			}
+			return leaves
+		}
+	}
+	return nil
+}
+
+// buildURL builds the path from root to n with params filled by values.
+func buildURL(n *node, values map[string]string) (string, error) {
+	var segments []string
+	for ; n != nil; n = n.parent {
+		switch n.ntype {
+		case static:
+			segments = append(segments, n.segment)
+		case param, matchAll:
+			value, ok := values[n.paramName]
+			if !ok {
+				return "", fmt.Errorf("missing param %s", n.paramName)
+			}
+			parts := []string{value}
+			if n.ntype == matchAll {
+				parts = strings.Split(value, "/")
+			}
+			for i, part := range parts {
+				parts[i] = url.PathEscape(part)
+			}
+			segments = append(segments, strings.Join(parts, "/"))
+		}
+	}
+	var buf strings.Builder
+	for i := len(segments) - 1; i >= 0; i-- {
+		buf.WriteString(segments[i])
+	}
+	if buf.Len() == 0 {
+		return "/", nil
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func routesTestHandler(ctx *Context) {}
+
+func routesTestFilter(ctx *Context, next Handler) {
+	next.Serve(ctx)
+}
+
+func TestRoutes(t *testing.T) {
+	convey.Convey("Given a Hador with routes", t, func() {
+		sub := New()
+		sub.Get("/invoices", routesTestHandler)
+
+		h := New()
+		h.Group("/users", func(r Router) {
+			r.Get("/{id:\\d+:integer:user id}", routesTestHandler).
+				Name("user").
+				SwaggerOperation().DocSumDesc("get user", "")
+			r.Post("/", routesTestHandler)
+		}, FilterFunc(routesTestFilter))
+		h.Get("/pages/{name?:::page=index}", routesTestHandler)
+		h.Host("{tenant}.example.com", func(r Router) {
+			r.Delete("/cache", routesTestHandler)
+		})
+		h.Mount("/billing", sub)
+
+		routes := h.Routes()
+		find := func(method Method, host, pattern string) *RouteInfo {
+			for i, r := range routes {
+				if r.Method == method && r.Host == host && r.Pattern == pattern {
+					return &routes[i]
+				}
+			}
+			return nil
+		}
+
+		convey.Convey("route info", func() {
+			r := find(GET, "", "/users/{id:\\d+:integer:user id}")
+			convey.So(r, convey.ShouldNotBeNil)
+			convey.So(r.Name, convey.ShouldEqual, "user")
+			convey.So(r.Summary, convey.ShouldEqual, "get user")
+			convey.So(r.Handler, convey.ShouldEndWith, ".routesTestHandler")
+			convey.So(r.Filters, convey.ShouldHaveLength, 1)
+			convey.So(r.Filters[0], convey.ShouldEndWith, ".routesTestFilter")
+			convey.So(r.Params, convey.ShouldResemble, []ParamInfo{
+				{Name: "id", Regexp: "\\d+", DataType: "integer"},
+			})
+			convey.So(find(POST, "", "/users"), convey.ShouldNotBeNil)
+		})
+		convey.Convey("optional params", func() {
+			r := find(GET, "", "/pages/{name?:::page=index}")
+			convey.So(r, convey.ShouldNotBeNil)
+			convey.So(r.Params[0].Optional, convey.ShouldBeTrue)
+			convey.So(r.Params[0].Default, convey.ShouldEqual, "index")
+			alias := find(GET, "", "/pages")
+			convey.So(alias, convey.ShouldNotBeNil)
+			convey.So(alias.Handler, convey.ShouldEndWith, ".routesTestHandler")
+		})
+		convey.Convey("hosts and mounted apps", func() {
+			convey.So(find(DELETE, "{tenant}.example.com", "/cache"), convey.ShouldNotBeNil)
+			convey.So(find(GET, "", "/billing/invoices"), convey.ShouldNotBeNil)
+			for _, r := range routes {
+				convey.So(r.Handler, convey.ShouldNotEndWith, ".mounted")
+			}
+		})
+		convey.Convey("sorted", func() {
+			for i := 1; i < len(routes); i++ {
+				prev, cur := routes[i-1], routes[i]
+				if prev.Host == cur.Host {
+					convey.So(prev.Pattern <= cur.Pattern, convey.ShouldBeTrue)
+				}
+			}
+		})
+		convey.Convey("table", func() {
+			var buf bytes.Buffer
+			convey.So(WriteRoutes(&buf, routes), convey.ShouldBeNil)
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			convey.So(lines, convey.ShouldHaveLength, len(routes)+1)
+			convey.So(lines[0], convey.ShouldStartWith, "METHOD")
+			convey.So(buf.String(), convey.ShouldContainSubstring, "{tenant}.example.com/cache")
+		})
+		convey.Convey("debug handler", func() {
+			h.Get("/debug/routes", h.RoutesHandler())
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/debug/routes", nil)
+			h.ServeHTTP(resp, req)
+			var infos []RouteInfo
+			convey.So(json.Unmarshal(resp.Body.Bytes(), &infos), convey.ShouldBeNil)
+			convey.So(infos, convey.ShouldHaveLength, len(routes)+1)
+
+			resp = httptest.NewRecorder()
+			req, _ = http.NewRequest("GET", "/debug/routes?format=text", nil)
+			h.ServeHTTP(resp, req)
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldStartWith, "text/plain")
+			convey.So(resp.Body.String(), convey.ShouldStartWith, "METHOD")
+		})
+	})
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a server-sent event.
+type Event struct {
+	ID    string
+	Event string
+	// Data is written as is if it's string or []byte, or in JSON format otherwise.
+	// Multi-line data is split into data fields.
+	Data interface{}
+	// Retry tells the client how long to wait before reconnecting if not 0.
+	Retry time.Duration
+}
+
+// SSE is a stream of server-sent events created by Context.SSE. It stops when the
+// client disconnects, and is closed when the request ends at the latest.
+type SSE struct {
+	// LastEventID is the Last-Event-ID header sent by reconnecting clients, where
+	// the stream should be resumed from.
+	LastEventID string
+
+	ctx     *Context
+	done    <-chan struct{}
+	mu      sync.Mutex
+	err     error
+	stop    chan struct{}
+	closing sync.Once
+	wg      sync.WaitGroup
+}
+
+var errInvalidEventField = errors.New("id and event of SSE shouldn't contain newlines")
+
+// SSE starts streaming server-sent events as the response.
+func (ctx *Context) SSE() *SSE {
+	header := ctx.Response.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Length")
+	ctx.WriteHeader(http.StatusOK)
+	ctx.Response.Flush()
+
+	s := &SSE{
+		LastEventID: ctx.Request.Header.Get("Last-Event-ID"),
+		ctx:         ctx,
+		done:        ctx.Request.Context().Done(),
+		stop:        make(chan struct{}),
+	}
+	ctx.sse = s
+	return s
+}
+
+// Done returns a channel closed when the client disconnects.
+func (s *SSE) Done() <-chan struct{} {
+	return s.done
+}
+
+// Send sends e to the client.
+func (s *SSE) Send(e Event) error {
+	if strings.ContainsAny(e.ID, "\r\n") || strings.ContainsAny(e.Event, "\r\n") {
+		return errInvalidEventField
+	}
+	var buf strings.Builder
+	if e.ID != "" {
+		buf.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Event != "" {
+		buf.WriteString("event: " + e.Event + "\n")
+	}
+	if e.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(int64(e.Retry/time.Millisecond), 10) + "\n")
+	}
+	var data string
+	switch d := e.Data.(type) {
+	case nil:
+	case string:
+		data = d
+	case []byte:
+		data = string(d)
+	default:
+		b, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+	if e.Data != nil {
+		data = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(data)
+		for _, line := range strings.Split(data, "\n") {
+			buf.WriteString("data: " + line + "\n")
+		}
+	}
+	buf.WriteString("\n")
+	return s.write(buf.String())
+}
+
+// Comment sends a comment, which is ignored by clients.
+func (s *SSE) Comment(comment string) error {
+	var buf strings.Builder
+	for _, line := range strings.Split(comment, "\n") {
+		buf.WriteString(": " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return s.write(buf.String())
+}
+
+func (s *SSE) write(frame string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	select {
+	case <-s.done:
+		s.err = s.ctx.Request.Context().Err()
+		return s.err
+	case <-s.stop:
+		s.err = errors.New("SSE closed")
+		return s.err
+	default:
+	}
+	if _, err := s.ctx.Response.WriteString(frame); err != nil {
+		s.err = err
+		return err
+	}
+	s.ctx.Response.Flush()
+	return nil
+}
+
+// Heartbeat sends comments every interval in background to keep the connection
+// alive through proxies, until the stream stops.
+func (s *SSE) Heartbeat(interval time.Duration) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if s.write(":\n\n") != nil {
+					return
+				}
+			case <-s.done:
+				return
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the stream and waits for heartbeats to exit. It's called when the
+// request ends as well.
+func (s *SSE) Close() {
+	s.closing.Do(func() {
+		close(s.stop)
+	})
+	s.wg.Wait()
+}
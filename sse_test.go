@@ -0,0 +1,103 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestSSE(t *testing.T) {
+	convey.Convey("Given Hador streaming events", t, func() {
+		h := New()
+		h.Get("/events", func(ctx *Context) {
+			s := ctx.SSE()
+			s.Send(Event{ID: "2", Event: "greet", Data: "hello\nworld", Retry: 3 * time.Second})
+			s.Send(Event{Data: map[string]string{"after": s.LastEventID}})
+			s.Comment("bye")
+			convey.So(s.Send(Event{ID: "a\nb"}), convey.ShouldEqual, errInvalidEventField)
+		})
+
+		convey.Convey("frames are written", func() {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/events", nil)
+			req.Header.Set("Last-Event-ID", "1")
+			h.ServeHTTP(resp, req)
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldEqual, "text/event-stream")
+			convey.So(resp.Header().Get("Cache-Control"), convey.ShouldEqual, "no-cache")
+			convey.So(resp.Flushed, convey.ShouldBeTrue)
+			convey.So(resp.Body.String(), convey.ShouldEqual,
+				"id: 2\nevent: greet\nretry: 3000\ndata: hello\ndata: world\n\n"+
+					"data: {\"after\":\"1\"}\n\n"+
+					": bye\n\n")
+		})
+
+		convey.Convey("heartbeats are sent until the client disconnects", func() {
+			stopped := make(chan error, 1)
+			h.Get("/heartbeat", func(ctx *Context) {
+				s := ctx.SSE()
+				s.Heartbeat(time.Millisecond)
+				<-s.Done()
+				stopped <- s.Send(Event{Data: "late"})
+			})
+			server := httptest.NewServer(h)
+			defer server.Close()
+
+			reqCtx, cancel := context.WithCancel(context.Background())
+			req, _ := http.NewRequestWithContext(reqCtx, "GET", server.URL+"/heartbeat", nil)
+			resp, err := http.DefaultClient.Do(req)
+			convey.So(err, convey.ShouldBeNil)
+			r := bufio.NewReader(resp.Body)
+			line, _ := r.ReadString('\n')
+			convey.So(line, convey.ShouldEqual, ":\n")
+			cancel()
+			resp.Body.Close()
+
+			select {
+			case err := <-stopped:
+				convey.So(err, convey.ShouldNotBeNil)
+			case <-time.After(time.Second):
+				t.Fatal("handler not stopped")
+			}
+		})
+	})
+}
+
+func TestSSEClose(t *testing.T) {
+	convey.Convey("Heartbeats stop when the request ends", t, func() {
+		h := New()
+		var s *SSE
+		h.Get("/", func(ctx *Context) {
+			s = ctx.SSE()
+			s.Heartbeat(time.Hour)
+		})
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		h.ServeHTTP(resp, req)
+		convey.So(s.Comment("closed"), convey.ShouldNotBeNil)
+		convey.So(strings.Contains(resp.Body.String(), "closed"), convey.ShouldBeFalse)
+	})
+}
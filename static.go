@@ -18,7 +18,9 @@
 package hador
 
 import (
+	"mime"
 	"net/http"
+	"path/filepath"
 	"strings"
 )
 
@@ -27,6 +29,9 @@ type Static struct {
 	Prefix    string
 	IndexFile string
 	Dir       http.FileSystem
+	// Precompressed serves siblings like "app.js.br" or "app.js.gz" instead if
+	// they exist and the client accepts the encoding.
+	Precompressed bool
 }
 
 // NewStatic creates new Static instance
@@ -90,9 +95,58 @@ func (s *Static) Filter(ctx *Context, next Handler) {
 			next.Serve(ctx)
 			return
 		}
+		if s.servePrecompressed(ctx, indexPath) {
+			return
+		}
 		http.ServeContent(ctx.Response, ctx.Request, indexPath, indexfs.ModTime(), indexFile)
 		return
 	}
 
+	if s.servePrecompressed(ctx, path) {
+		return
+	}
 	http.ServeContent(ctx.Response, ctx.Request, path, fs.ModTime(), file)
 }
+
+// precompressedExts are extensions of precompressed siblings by content coding.
+var precompressedExts = map[string]string{"br": ".br", "gzip": ".gz"}
+
+// servePrecompressed serves the precompressed sibling of path negotiated by
+// Accept-Encoding, and returns false if there isn't one.
+func (s *Static) servePrecompressed(ctx *Context, path string) bool {
+	if !s.Precompressed {
+		return false
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		return false
+	}
+	header := ctx.Response.Header()
+	if !headerHasToken(header, "Vary", "Accept-Encoding") {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	var offers []string
+	for _, coding := range []string{"br", "gzip"} {
+		if f, err := s.Dir.Open(path + precompressedExts[coding]); err == nil {
+			f.Close()
+			offers = append(offers, coding)
+		}
+	}
+	coding := negotiateEncoding(ctx.Request.Header.Get("Accept-Encoding"), offers...)
+	if coding == "" {
+		return false
+	}
+	file, err := s.Dir.Open(path + precompressedExts[coding])
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Encoding", coding)
+	http.ServeContent(ctx.Response, ctx.Request, path, info.ModTime(), file)
+	return true
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// flushWriter writes into the response and remembers the first error.
+type flushWriter struct {
+	rw  ResponseWriter
+	err error
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.rw.Write(p)
+	w.err = err
+	return n, err
+}
+
+// Stream calls step repeatedly while it returns true, flushing what it writes to
+// the client after each call. As writes block until the client reads, steps are
+// paced by it. Streaming stops with the error if the client disconnects or writing
+// fails, or nil once step returns false.
+func (ctx *Context) Stream(step func(w io.Writer) bool) error {
+	ctx.Response.Header().Del("Content-Length")
+	w := &flushWriter{rw: ctx.Response}
+	done := ctx.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return ctx.Request.Context().Err()
+		default:
+		}
+		more := step(w)
+		if w.err != nil {
+			return w.err
+		}
+		ctx.Response.Flush()
+		if !more {
+			return nil
+		}
+	}
+}
+
+// Attachment sends content of r as a file named filename to download. Range
+// requests are supported if r is an io.ReadSeeker.
+func (ctx *Context) Attachment(filename string, r io.Reader) error {
+	ctx.SetHeader("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": filename,
+	}))
+	if rs, ok := r.(io.ReadSeeker); ok {
+		http.ServeContent(ctx.Response, ctx.Request, filename, time.Time{}, rs)
+		return nil
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	ctx.SetHeader("Content-Type", contentType)
+	_, err := io.Copy(ctx.Response, r)
+	return err
+}
+
+// ServeFile serves the file at path with Range and conditional requests supported.
+// 404 is responded if it doesn't exist or is a directory.
+func (ctx *Context) ServeFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			ctx.OnError(http.StatusNotFound)
+		} else {
+			ctx.HandleError(err)
+		}
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		ctx.HandleError(err)
+		return err
+	}
+	if info.IsDir() {
+		ctx.OnError(http.StatusNotFound)
+		return errors.New(path + " is a directory")
+	}
+	http.ServeContent(ctx.Response, ctx.Request, info.Name(), info.ModTime(), f)
+	return nil
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestStream(t *testing.T) {
+	convey.Convey("Given Hador streaming responses", t, func() {
+		h := New()
+		h.Get("/count", func(ctx *Context) {
+			i := 0
+			ctx.Stream(func(w io.Writer) bool {
+				i++
+				fmt.Fprintf(w, "%d\n", i)
+				return i < 3
+			})
+		})
+		stopped := make(chan error, 1)
+		h.Get("/forever", func(ctx *Context) {
+			stopped <- ctx.Stream(func(w io.Writer) bool {
+				io.WriteString(w, "tick\n")
+				time.Sleep(time.Millisecond)
+				return true
+			})
+		})
+
+		convey.Convey("steps are flushed", func() {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/count", nil)
+			h.ServeHTTP(resp, req)
+			convey.So(resp.Flushed, convey.ShouldBeTrue)
+			convey.So(resp.Body.String(), convey.ShouldEqual, "1\n2\n3\n")
+		})
+
+		convey.Convey("streaming stops when the client disconnects", func() {
+			server := httptest.NewServer(h)
+			defer server.Close()
+			reqCtx, cancel := context.WithCancel(context.Background())
+			req, _ := http.NewRequestWithContext(reqCtx, "GET", server.URL+"/forever", nil)
+			resp, err := http.DefaultClient.Do(req)
+			convey.So(err, convey.ShouldBeNil)
+			line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+			convey.So(line, convey.ShouldEqual, "tick\n")
+			cancel()
+			resp.Body.Close()
+			select {
+			case err := <-stopped:
+				convey.So(err, convey.ShouldNotBeNil)
+			case <-time.After(time.Second):
+				t.Fatal("stream not stopped")
+			}
+		})
+	})
+}
+
+func TestServeFile(t *testing.T) {
+	convey.Convey("Given Hador serving files", t, func() {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "report.txt")
+		os.WriteFile(path, []byte("0123456789"), 0644)
+
+		h := New()
+		h.Get("/file", func(ctx *Context) {
+			ctx.ServeFile(path)
+		})
+		h.Get("/dir", func(ctx *Context) {
+			ctx.ServeFile(dir)
+		})
+		h.Get("/download", func(ctx *Context) {
+			ctx.Attachment("résumé 2016.txt", strings.NewReader("0123456789"))
+		})
+		h.Get("/stream", func(ctx *Context) {
+			ctx.Attachment("data.json", io.MultiReader(strings.NewReader("[]")))
+		})
+		serve := func(path, rangeHeader string) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			if rangeHeader != "" {
+				req.Header.Set("Range", rangeHeader)
+			}
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+
+		convey.Convey("files support Range", func() {
+			resp := serve("/file", "")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldStartWith, "text/plain")
+			convey.So(resp.Header().Get("Last-Modified"), convey.ShouldNotBeEmpty)
+			resp = serve("/file", "bytes=2-4")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusPartialContent)
+			convey.So(resp.Body.String(), convey.ShouldEqual, "234")
+			convey.So(serve("/dir", "").Code, convey.ShouldEqual, http.StatusNotFound)
+		})
+
+		convey.Convey("attachments are named", func() {
+			resp := serve("/download", "bytes=-3")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusPartialContent)
+			convey.So(resp.Body.String(), convey.ShouldEqual, "789")
+			convey.So(resp.Header().Get("Content-Disposition"), convey.ShouldEqual,
+				"attachment; filename*=utf-8''r%C3%A9sum%C3%A9%202016.txt")
+
+			resp = serve("/stream", "bytes=0-1")
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldStartWith, "application/json")
+			convey.So(resp.Header().Get("Content-Disposition"), convey.ShouldEqual, "attachment; filename=data.json")
+			convey.So(resp.Body.String(), convey.ShouldEqual, "[]")
+		})
+	})
+}
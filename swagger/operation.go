@@ -28,7 +28,7 @@ type Operation struct {
 	ExternalDocs *ExternalDocs `json:"externalDocs,omitempty"`
 	OperationID  string        `json:"operationId,omitempty"`
 	Parameters   Parameters    `json:"parameters,omitempty"`
-	Consumes     []string      `json:"comsumes,omitempty"`
+	Consumes     []string      `json:"consumes,omitempty"`
 	Produces     []string      `json:"produces,omitempty"`
 	Responses    Responses     `json:"responses,omitempty"`
 	Schemes      []string      `json:"schemes,omitempty"`
@@ -0,0 +1,225 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// TemplateConfig configures templates loaded by Hador.LoadTemplates.
+type TemplateConfig struct {
+	// FS holds template files, named by their paths without Extension, e.g.
+	// "users/show". Use embed.FS in production, or os.DirFS with Reload in
+	// development.
+	FS fs.FS
+	// Reload re-parses templates if any file in FS changed before rendering.
+	Reload bool
+	// Extension of template files, ".html" if empty.
+	Extension string
+	// LayoutDir and PartialDir are directories of layouts and partials, "layouts"
+	// and "partials" if empty. They're available to all pages, e.g.
+	// {{template "partials/nav" .}}.
+	LayoutDir  string
+	PartialDir string
+	// Layout is the layout pages are rendered in, e.g. "layouts/base", where the
+	// page is included by {{template "content" .}}. Pages could override blocks
+	// of the layout by {{define}}. Pages are rendered alone if empty.
+	Layout string
+	// StaticPrefix is the prefix of URLs returned by function static.
+	StaticPrefix string
+	// Funcs are added to templates besides the builtin ones:
+	//   url "name" "key" "value"... returns URL of the named route by Hador.URLFor.
+	//   static "css/app.css" returns URL of the static asset under StaticPrefix.
+	Funcs template.FuncMap
+}
+
+// Templates renders pages of html/template.
+type Templates struct {
+	config TemplateConfig
+	funcs  template.FuncMap
+
+	mu    sync.RWMutex
+	pages map[string]*template.Template
+	stamp string
+}
+
+// LoadTemplates parses templates in config.FS, which are used by
+// Context.RenderHTML.
+func (h *Hador) LoadTemplates(config TemplateConfig) (*Templates, error) {
+	if config.Extension == "" {
+		config.Extension = ".html"
+	}
+	if config.LayoutDir == "" {
+		config.LayoutDir = "layouts"
+	}
+	if config.PartialDir == "" {
+		config.PartialDir = "partials"
+	}
+	ts := &Templates{
+		config: config,
+		funcs: template.FuncMap{
+			"url": h.URLFor,
+			"static": func(asset string) string {
+				return path.Join("/", config.StaticPrefix, asset)
+			},
+		},
+	}
+	for name, fn := range config.Funcs {
+		ts.funcs[name] = fn
+	}
+	if err := ts.load(); err != nil {
+		return nil, err
+	}
+	h.templates = ts
+	return ts, nil
+}
+
+// load parses all templates, and replaces the current ones if succeeded.
+func (ts *Templates) load() error {
+	stamp, err := ts.fingerprint()
+	if err != nil {
+		return err
+	}
+	base := template.New("").Funcs(ts.funcs)
+	var pages []string
+	err = ts.walk(func(file, name string, info fs.FileInfo) error {
+		if !ts.isShared(name) {
+			pages = append(pages, file)
+			return nil
+		}
+		return parseTemplateFile(base, ts.config.FS, file, name)
+	})
+	if err != nil {
+		return err
+	}
+	compiled := make(map[string]*template.Template, len(pages))
+	for _, file := range pages {
+		name := strings.TrimSuffix(file, ts.config.Extension)
+		t, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if err := parseTemplateFile(t, ts.config.FS, file, name); err != nil {
+			return err
+		}
+		if _, err := t.AddParseTree("content", t.Lookup(name).Tree); err != nil {
+			return err
+		}
+		compiled[name] = t
+	}
+
+	ts.mu.Lock()
+	ts.pages = compiled
+	ts.stamp = stamp
+	ts.mu.Unlock()
+	return nil
+}
+
+func parseTemplateFile(t *template.Template, fsys fs.FS, file, name string) error {
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return err
+	}
+	_, err = t.New(name).Parse(string(data))
+	return err
+}
+
+func (ts *Templates) isShared(name string) bool {
+	return strings.HasPrefix(name, ts.config.LayoutDir+"/") ||
+		strings.HasPrefix(name, ts.config.PartialDir+"/")
+}
+
+// walk calls fn with path, name and info of template files.
+func (ts *Templates) walk(fn func(file, name string, info fs.FileInfo) error) error {
+	return fs.WalkDir(ts.config.FS, ".", func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(file) != ts.config.Extension {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(file, strings.TrimSuffix(file, ts.config.Extension), info)
+	})
+}
+
+// fingerprint summarizes names, sizes and modification times of template files.
+func (ts *Templates) fingerprint() (string, error) {
+	if !ts.config.Reload {
+		return "", nil
+	}
+	var buf strings.Builder
+	err := ts.walk(func(file, name string, info fs.FileInfo) error {
+		fmt.Fprintf(&buf, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return buf.String(), err
+}
+
+// Render renders page name with data into w.
+func (ts *Templates) Render(w io.Writer, name string, data interface{}) error {
+	if ts.config.Reload {
+		stamp, err := ts.fingerprint()
+		if err != nil {
+			return err
+		}
+		ts.mu.RLock()
+		changed := stamp != ts.stamp
+		ts.mu.RUnlock()
+		if changed {
+			if err := ts.load(); err != nil {
+				return err
+			}
+		}
+	}
+	ts.mu.RLock()
+	t, ok := ts.pages[name]
+	ts.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("template %s not found", name)
+	}
+	if layout := ts.config.Layout; layout != "" && t.Lookup(layout) != nil {
+		return t.ExecuteTemplate(w, layout, data)
+	}
+	return t.ExecuteTemplate(w, name, data)
+}
+
+// RenderHTML renders page name of templates loaded by Hador.LoadTemplates with
+// data, and sets status if provided.
+func (ctx *Context) RenderHTML(name string, data interface{}, status ...int) error {
+	if ctx.templates == nil {
+		return fmt.Errorf("templates not loaded")
+	}
+	var buf bytes.Buffer
+	if err := ctx.templates.Render(&buf, name, data); err != nil {
+		return err
+	}
+	ctx.SetHeader("Content-Type", contentTypeHTML)
+	_, err := ctx.WriteStatus(buf.Bytes(), status...)
+	return err
+}
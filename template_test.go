@@ -0,0 +1,108 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestTemplates(t *testing.T) {
+	convey.Convey("Given Hador with templates", t, func() {
+		h := New()
+		h.Get("/users/{id}/posts/{page?:::page=1}", func(ctx *Context) {}).Name("posts")
+		h.Get("/files/{path*}", func(ctx *Context) {}).Name("file")
+		h.Get("/users/{id}", func(ctx *Context) {
+			ctx.RenderHTML("users/show", ctx.Params().GetStringMust("id", ""), http.StatusAccepted)
+		})
+
+		convey.Convey("URLFor builds URLs of named routes", func() {
+			u, err := h.URLFor("posts", "id", "a b", "page", "2")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(u, convey.ShouldEqual, "/users/a%20b/posts/2")
+			u, _ = h.URLFor("posts", "id", "1")
+			convey.So(u, convey.ShouldEqual, "/users/1/posts")
+			u, _ = h.URLFor("file", "path", "a/b c")
+			convey.So(u, convey.ShouldEqual, "/files/a/b%20c")
+			_, err = h.URLFor("posts")
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = h.URLFor("nothing")
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		files := fstest.MapFS{
+			"layouts/base.html":   {Data: []byte(`<title>{{block "title" .}}Hador{{end}}</title>{{template "partials/nav" .}}{{template "content" .}}`)},
+			"partials/nav.html":   {Data: []byte(`<a href="{{url "posts" "id" . "page" "1"}}">posts</a><img src="{{static "logo.png"}}">`)},
+			"users/show.html":     {Data: []byte(`{{define "title"}}{{upper .}}{{end}}<p>{{.}}</p>`)},
+			"users/index.txt":     {Data: []byte(`ignored`)},
+			"layouts/broken.html": {Data: []byte(`{{template "content" .}}`)},
+		}
+
+		convey.Convey("pages are rendered in layout", func() {
+			_, err := h.LoadTemplates(TemplateConfig{
+				FS:           files,
+				Layout:       "layouts/base",
+				StaticPrefix: "/static",
+				Funcs:        template.FuncMap{"upper": strings.ToUpper},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/users/<jack>", nil)
+			h.ServeHTTP(resp, req)
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusAccepted)
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldEqual, contentTypeHTML)
+			convey.So(resp.Body.String(), convey.ShouldEqual,
+				`<title>&lt;JACK&gt;</title><a href="/users/%3Cjack%3E/posts/1">posts</a><img src="/static/logo.png"><p>&lt;jack&gt;</p>`)
+		})
+
+		convey.Convey("errors are reported", func() {
+			_, err := h.LoadTemplates(TemplateConfig{FS: files})
+			convey.So(err, convey.ShouldNotBeNil)
+			files["users/show.html"] = &fstest.MapFile{Data: []byte(`{{.}}`)}
+			ts, err := h.LoadTemplates(TemplateConfig{FS: files})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ts.Render(new(strings.Builder), "users/index", nil), convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("templates are reloaded in development", func() {
+			dir := t.TempDir()
+			page := filepath.Join(dir, "index.html")
+			os.WriteFile(page, []byte("v1"), 0644)
+			ts, err := h.LoadTemplates(TemplateConfig{FS: os.DirFS(dir), Reload: true})
+			convey.So(err, convey.ShouldBeNil)
+			var buf strings.Builder
+			ts.Render(&buf, "index", nil)
+			convey.So(buf.String(), convey.ShouldEqual, "v1")
+
+			os.WriteFile(page, []byte("v2!"), 0644)
+			os.Chtimes(page, time.Now().Add(time.Second), time.Now().Add(time.Second))
+			buf.Reset()
+			ts.Render(&buf, "index", nil)
+			convey.So(buf.String(), convey.ShouldEqual, "v2!")
+		})
+	})
+}
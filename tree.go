@@ -0,0 +1,153 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func (t nodeType) String() string {
+	switch t {
+	case static:
+		return "static"
+	case param:
+		return "param"
+	case matchAll:
+		return "matchAll"
+	}
+	return "unknown"
+}
+
+// label returns a one-line description of n, e.g. `"{id}" param regexp=\d+ [GET POST]`.
+func (n *node) label() string {
+	attrs := []string{fmt.Sprintf("%q", n.segment), n.ntype.String()}
+	if n.indices != "" {
+		attrs = append(attrs, fmt.Sprintf("indices=%q", n.indices))
+	}
+	if n.paramReg != nil {
+		attrs = append(attrs, "regexp="+n.paramRegstr)
+	}
+	if n.paramType != "" {
+		attrs = append(attrs, "type="+n.paramType)
+	}
+	if methods := n.leafMethods(); len(methods) > 0 {
+		attrs = append(attrs, "["+strings.Join(methods, " ")+"]")
+	}
+	return strings.Join(attrs, " ")
+}
+
+// leafMethods returns methods of leaves in order of Methods, count of alternative
+// leaves is appended if there are more than one, e.g. "GET*2".
+func (n *node) leafMethods() []string {
+	var methods []string
+	for _, method := range Methods {
+		count := 0
+		for l := n.leaves[method]; l != nil; l = l.next {
+			count++
+		}
+		switch {
+		case count == 1:
+			methods = append(methods, method.String())
+		case count > 1:
+			methods = append(methods, fmt.Sprintf("%s*%d", method, count))
+		}
+	}
+	return methods
+}
+
+// writeText writes the subtree into w as an indented text tree, children in the
+// order they're tried by match.
+func (n *node) writeText(w io.Writer, indent string) {
+	fmt.Fprintf(w, "%s%s\n", indent, n.label())
+	for _, ch := range n.children {
+		ch.writeText(w, indent+"  ")
+	}
+	for _, ch := range n.paramChildren {
+		ch.writeText(w, indent+"  ")
+	}
+}
+
+// writeDOT writes nodes and edges of the subtree into w as Graphviz DOT statements,
+// id is the next unused node id, which is returned after the subtree written.
+func (n *node) writeDOT(w io.Writer, id int) int {
+	self := id
+	fmt.Fprintf(w, "\tn%d [label=\"%s\"];\n", self, dotEscape(n.label()))
+	id++
+	for i, ch := range n.children {
+		fmt.Fprintf(w, "\tn%d -> n%d [label=\"%s\"];\n", self, id, dotEscape(n.indices[i:i+1]))
+		id = ch.writeDOT(w, id)
+	}
+	for i, ch := range n.paramChildren {
+		fmt.Fprintf(w, "\tn%d -> n%d [label=\"%d\", style=dashed];\n", self, id, i)
+		id = ch.writeDOT(w, id)
+	}
+	return id
+}
+
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// WriteTree writes the routing tree into w as an indented text tree, trees of hosts
+// added by Host followed. It shows how routes are split into nodes, which helps to
+// debug priority issues.
+func (h *Hador) WriteTree(w io.Writer) error {
+	t := h.routes()
+	var buf bytes.Buffer
+	t.root.writeText(&buf, "")
+	for _, hr := range t.hosts {
+		fmt.Fprintf(&buf, "\nhost %s\n", hr.pattern)
+		hr.root.writeText(&buf, "")
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// WriteTreeDOT writes the routing tree into w as a Graphviz DOT digraph, trees of
+// hosts added by Host are drawn as clusters.
+func (h *Hador) WriteTreeDOT(w io.Writer) error {
+	t := h.routes()
+	var buf bytes.Buffer
+	buf.WriteString("digraph hador {\n\tnode [shape=box];\n")
+	id := t.root.writeDOT(&buf, 0)
+	for i, hr := range t.hosts {
+		fmt.Fprintf(&buf, "\tsubgraph cluster_%d {\n\tlabel=\"%s\";\n", i, dotEscape(hr.pattern))
+		id = hr.root.writeDOT(&buf, id)
+		buf.WriteString("\t}\n")
+	}
+	buf.WriteString("}\n")
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// TreeHandler returns a debug handler serving the routing tree as text, or as
+// Graphviz DOT if query "format" is "dot".
+func (h *Hador) TreeHandler() Handler {
+	return HandlerFunc(func(ctx *Context) {
+		if ctx.Request.URL.Query().Get("format") == "dot" {
+			ctx.SetHeader("Content-Type", "text/vnd.graphviz; charset=utf-8")
+			h.WriteTreeDOT(ctx.Response)
+			return
+		}
+		ctx.SetHeader("Content-Type", "text/plain; charset=utf-8")
+		h.WriteTree(ctx.Response)
+	})
+}
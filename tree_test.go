@@ -0,0 +1,76 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestTree(t *testing.T) {
+	convey.Convey("Given a Hador with routes", t, func() {
+		h := New()
+		h.Get("/users", newSimpleHandler("users"))
+		h.Get("/users/{id:\\d+}", newSimpleHandler("user"))
+		h.Delete("/users/{id:\\d+}", newSimpleHandler("delete"))
+		h.Get("/users/{name}", newSimpleHandler("name")).MatchQuery("v")
+		h.Get("/users/{name}", newSimpleHandler("name"))
+		h.Host("api.example.com", func(r Router) {
+			r.Get("/ping", newSimpleHandler("pong"))
+		})
+
+		convey.Convey("text tree", func() {
+			var buf bytes.Buffer
+			convey.So(h.WriteTree(&buf), convey.ShouldBeNil)
+			convey.So(buf.String(), convey.ShouldEqual, strings.Join([]string{
+				`"/users" static indices="/" [GET]`,
+				`  "/" static`,
+				`    "{id:\\d+}" param regexp=\d+ [GET DELETE]`,
+				`    "{name}" param [GET*2]`,
+				``,
+				`host api.example.com`,
+				`"/ping" static [GET]`,
+				``,
+			}, "\n"))
+		})
+		convey.Convey("dot", func() {
+			var buf bytes.Buffer
+			convey.So(h.WriteTreeDOT(&buf), convey.ShouldBeNil)
+			dot := buf.String()
+			convey.So(dot, convey.ShouldStartWith, "digraph hador {\n")
+			convey.So(dot, convey.ShouldEndWith, "}\n")
+			convey.So(dot, convey.ShouldContainSubstring, `n0 -> n1 [label="/"];`)
+			convey.So(dot, convey.ShouldContainSubstring, `n1 -> n2 [label="0", style=dashed];`)
+			convey.So(dot, convey.ShouldContainSubstring, `n2 [label="\"{id:\\\\d+}\" param regexp=\\d+ [GET DELETE]"];`)
+			convey.So(dot, convey.ShouldContainSubstring, `subgraph cluster_0 {`)
+		})
+		convey.Convey("debug handler", func() {
+			h.Get("/debug/tree", h.TreeHandler())
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/debug/tree?format=dot", nil)
+			h.ServeHTTP(resp, req)
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldStartWith, "text/vnd.graphviz")
+			convey.So(resp.Body.String(), convey.ShouldStartWith, "digraph")
+		})
+	})
+}
@@ -0,0 +1,335 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/Xuyuanp/hador/swagger"
+)
+
+// Validator is implemented by request types of typed handlers, which is called
+// after bound. The request is rejected with 400, or the status of Error or HTTPError
+// returned.
+type Validator interface {
+	Validate() error
+}
+
+var (
+	contextType = reflect.TypeOf((*Context)(nil))
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// typedHandler serves by function in form of func(*Context[, In]) [Out][, error].
+// In is a struct, or pointer to struct, bound from request: the body is decoded by
+// Content-Type, and fields tagged with `path`, `query`, `header` or `form` are set from
+// Params, query, headers and form values. Out is rendered as JSON, or XML if accepted,
+// and error is responded by Context.HandleError.
+type typedHandler struct {
+	fn reflect.Value
+	// in is type of the request, and inStruct is the struct type of it.
+	in, inStruct reflect.Type
+	// outIndex and errIndex are indexes of results, -1 if absent.
+	outIndex, errIndex int
+}
+
+func newTypedHandler(fn interface{}) (*typedHandler, error) {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	th := &typedHandler{fn: v, outIndex: -1, errIndex: -1}
+
+	if t.NumIn() == 0 || t.NumIn() > 2 || t.In(0) != contextType || t.IsVariadic() {
+		return nil, fmt.Errorf("handler %s should accept *Context and an optional request", t)
+	}
+	if t.NumIn() == 2 {
+		th.in, th.inStruct = t.In(1), t.In(1)
+		if th.inStruct.Kind() == reflect.Ptr {
+			th.inStruct = th.inStruct.Elem()
+		}
+		if th.inStruct.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("request of handler %s should be a struct", t)
+		}
+	}
+	switch t.NumOut() {
+	case 0:
+	case 1:
+		if t.Out(0) == errorType {
+			th.errIndex = 0
+		} else {
+			th.outIndex = 0
+		}
+	case 2:
+		if t.Out(1) != errorType {
+			return nil, fmt.Errorf("the last result of handler %s should be error", t)
+		}
+		th.outIndex, th.errIndex = 0, 1
+	default:
+		return nil, fmt.Errorf("handler %s returns too many results", t)
+	}
+	return th, nil
+}
+
+func (th *typedHandler) Serve(ctx *Context) {
+	args := []reflect.Value{reflect.ValueOf(ctx)}
+	if th.in != nil {
+		in, err := th.bind(ctx)
+		if err != nil {
+			if _, ok := errorStatus(err); ok {
+				ctx.HandleError(err)
+			} else {
+				ctx.OnError(http.StatusBadRequest, err)
+			}
+			return
+		}
+		args = append(args, in)
+	}
+
+	results := th.fn.Call(args)
+	if th.errIndex >= 0 {
+		if err, _ := results[th.errIndex].Interface().(error); err != nil {
+			ctx.HandleError(err)
+			return
+		}
+	}
+	if th.outIndex >= 0 {
+		renderResult(ctx, results[th.outIndex])
+	}
+}
+
+func (th *typedHandler) bind(ctx *Context) (reflect.Value, error) {
+	ptr := reflect.New(th.inStruct)
+	req := ctx.Request
+	if req.Body != nil && req.Body != http.NoBody && req.ContentLength != 0 {
+		if err := ctx.Decode(ptr.Interface()); err != nil && err != io.EOF {
+			return ptr, err
+		}
+	}
+	if err := bindFields(ptr.Elem(), ctx); err != nil {
+		return ptr, err
+	}
+	if v, ok := ptr.Interface().(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return ptr, err
+		}
+	}
+	if th.in.Kind() == reflect.Ptr {
+		return ptr, nil
+	}
+	return ptr.Elem(), nil
+}
+
+// bindTags are struct tags of fields bound from request besides body, in order of precedence.
+var bindTags = []string{"path", "query", "header", "form"}
+
+func bindFields(v reflect.Value, ctx *Context) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := bindFields(v.Field(i), ctx); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		for _, tag := range bindTags {
+			name := field.Tag.Get(tag)
+			if name == "" {
+				continue
+			}
+			values := requestValues(ctx, tag, name)
+			if len(values) == 0 {
+				continue
+			}
+			if err := setValue(v.Field(i), values); err != nil {
+				return fmt.Errorf("invalid %s %s: %s", tag, name, err)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func requestValues(ctx *Context, tag, name string) []string {
+	switch tag {
+	case "path":
+		if value, ok := ctx.Params().get(name); ok {
+			return []string{value}
+		}
+	case "query":
+		return ctx.Request.URL.Query()[name]
+	case "header":
+		return ctx.Request.Header[http.CanonicalHeaderKey(name)]
+	case "form":
+		return ctx.Request.PostForm[name]
+	}
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setValue sets v from values, slices take all of them and the others take the first.
+func setValue(v reflect.Value, values []string) error {
+	if reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(values[0]))
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), values); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(s.Index(i), []string{value}); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	value := values[0]
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// renderResult renders v by Context.Render. A nil pointer results in 204 No Content.
+func renderResult(ctx *Context, v reflect.Value) {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		ctx.WriteHeader(http.StatusNoContent)
+		return
+	}
+	// errors are responded by Render.
+	ctx.Render(v.Interface())
+}
+
+// docOperation documents the request and response types into operation.
+func (th *typedHandler) docOperation(method Method, operation *swagger.Operation) {
+	if th.in != nil {
+		hasBody := docFields(th.inStruct, operation)
+		if hasBody && method != GET && method != HEAD {
+			operation.DocParameterBody("body", "", reflect.Zero(th.inStruct).Interface(), true)
+		}
+	}
+	if th.outIndex < 0 {
+		return
+	}
+	out := th.fn.Type().Out(th.outIndex)
+	if out.Kind() == reflect.Ptr {
+		out = out.Elem()
+	}
+	if out.Kind() == reflect.Struct {
+		operation.DocResponseModel("200", http.StatusText(http.StatusOK), reflect.Zero(out).Interface())
+	} else {
+		operation.DocResponseSimple("200", http.StatusText(http.StatusOK))
+	}
+}
+
+// docFields documents fields bound from query, headers and form as parameters,
+// path params are documented by routes. It returns true if there are body fields.
+func docFields(t reflect.Type, operation *swagger.Operation) (hasBody bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			hasBody = docFields(field.Type, operation) || hasBody
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		bound := false
+		for _, tag := range bindTags {
+			name := field.Tag.Get(tag)
+			if name == "" {
+				continue
+			}
+			bound = true
+			in := map[string]string{"query": "query", "header": "header", "form": "formData"}[tag]
+			if in != "" {
+				operation.DocParameter(swagger.Parameter{
+					Name:  name,
+					In:    in,
+					Items: paramItems(field.Type),
+				})
+			}
+			break
+		}
+		if !bound && field.Tag.Get("json") != "-" {
+			hasBody = true
+		}
+	}
+	return
+}
+
+func paramItems(t reflect.Type) swagger.Items {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return swagger.Items{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return swagger.Items{Type: "number"}
+	case reflect.Bool:
+		return swagger.Items{Type: "boolean"}
+	case reflect.Slice:
+		items := paramItems(t.Elem())
+		return swagger.Items{Type: "array", Items: &items, CollectionFormat: "multi"}
+	}
+	return swagger.Items{Type: "string"}
+}
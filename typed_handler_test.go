@@ -0,0 +1,150 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type typedUserReq struct {
+	ID      int      `path:"id" json:"-"`
+	Verbose bool     `query:"verbose" json:"-"`
+	Tags    []string `query:"tag" json:"-"`
+	Token   string   `header:"X-Token" json:"-"`
+	Name    string   `json:"name"`
+}
+
+func (req *typedUserReq) Validate() error {
+	if req.Name == "forbidden" {
+		return HTTPError(http.StatusForbidden)
+	}
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+type typedUser struct {
+	ID      int      `json:"id" xml:"id"`
+	Name    string   `json:"name" xml:"name"`
+	Verbose bool     `json:"verbose" xml:"verbose"`
+	Tags    []string `json:"tags" xml:"tags"`
+	Token   string   `json:"token" xml:"token"`
+}
+
+func TestTypedHandler(t *testing.T) {
+	convey.Convey("Given a Hador with typed handlers", t, func() {
+		h := New()
+		h.Put("/users/{id:int}", func(ctx *Context, req typedUserReq) (*typedUser, error) {
+			return &typedUser{ID: req.ID, Name: req.Name, Verbose: req.Verbose, Tags: req.Tags, Token: req.Token}, nil
+		})
+		h.Get("/users/{id}", func(ctx *Context, req *struct {
+			ID int `path:"id"`
+		}) (*typedUser, error) {
+			switch req.ID {
+			case 0:
+				return nil, nil
+			case 404:
+				return nil, HTTPError(http.StatusNotFound)
+			case 500:
+				return nil, errors.New("database is down")
+			}
+			return &typedUser{ID: req.ID}, nil
+		})
+		h.Get("/chan", func(ctx *Context) (map[string]interface{}, error) {
+			return map[string]interface{}{"c": make(chan int)}, nil
+		})
+		h.Post("/ping", func(ctx *Context) error {
+			ctx.WriteString("pong")
+			return nil
+		})
+
+		serve := func(method, path, body string, header http.Header) *httptest.ResponseRecorder {
+			resp := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, path, strings.NewReader(body))
+			for k, v := range header {
+				req.Header[k] = v
+			}
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+
+		convey.Convey("bind and render", func() {
+			resp := serve("PUT", "/users/42?verbose=true&tag=a&tag=b", `{"name":"jack"}`,
+				http.Header{"X-Token": {"secret"}, "Content-Type": {"application/json"}})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusOK)
+			var user typedUser
+			convey.So(json.Unmarshal(resp.Body.Bytes(), &user), convey.ShouldBeNil)
+			convey.So(user, convey.ShouldResemble, typedUser{
+				ID: 42, Name: "jack", Verbose: true, Tags: []string{"a", "b"}, Token: "secret",
+			})
+		})
+		convey.Convey("render XML if accepted", func() {
+			resp := serve("GET", "/users/1", "", http.Header{"Accept": {"application/xml"}})
+			convey.So(resp.Header().Get("Content-Type"), convey.ShouldStartWith, "application/xml")
+			convey.So(resp.Body.String(), convey.ShouldContainSubstring, "<id>1</id>")
+		})
+		convey.Convey("bad requests", func() {
+			convey.So(serve("PUT", "/users/42", `{"name":`, nil).Code, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(serve("PUT", "/users/42", `{}`, nil).Code, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(serve("PUT", "/users/42?verbose=yes", `{"name":"jack"}`, nil).Code, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(serve("PUT", "/users/42", `{"name":"forbidden"}`, nil).Code, convey.ShouldEqual, http.StatusForbidden)
+		})
+		convey.Convey("errors and empty results", func() {
+			convey.So(serve("GET", "/users/0", "", nil).Code, convey.ShouldEqual, http.StatusNoContent)
+			convey.So(serve("GET", "/users/404", "", nil).Code, convey.ShouldEqual, http.StatusNotFound)
+			resp := serve("GET", "/users/500", "", nil)
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusInternalServerError)
+			convey.So(resp.Body.String(), convey.ShouldNotContainSubstring, "database")
+			convey.So(serve("POST", "/ping", "", nil).Body.String(), convey.ShouldEqual, "pong")
+			convey.So(serve("GET", "/chan", "", nil).Code, convey.ShouldEqual, http.StatusInternalServerError)
+		})
+		convey.Convey("document", func() {
+			op := h.travelPaths()["/users/{id}"]["put"]
+			var ins []string
+			for _, p := range op.Parameters {
+				ins = append(ins, p.In+":"+p.Name)
+			}
+			convey.So(ins, convey.ShouldContain, "query:verbose")
+			convey.So(ins, convey.ShouldContain, "query:tag")
+			convey.So(ins, convey.ShouldContain, "header:X-Token")
+			convey.So(ins, convey.ShouldContain, "body:body")
+			convey.So(ins, convey.ShouldContain, "path:id")
+			convey.So(op.Responses["200"].Schema.Ref, convey.ShouldEqual, "#/definitions/hador.typedUser")
+		})
+		convey.Convey("unsupported signatures", func() {
+			for _, fn := range []interface{}{
+				func() {},
+				func(ctx *Context, id int) {},
+				func(ctx *Context) (int, int) { return 0, 0 },
+			} {
+				err := h.Register(func(r Router) {
+					r.Get("/bad", fn)
+				})
+				convey.So(err, convey.ShouldNotBeNil)
+			}
+		})
+	})
+}
@@ -0,0 +1,389 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Xuyuanp/hador/swagger"
+)
+
+// DefaultMaxValueSize is the max size of non-file multipart fields without
+// declared MaxSize, which are kept in memory.
+const DefaultMaxValueSize = 1 << 20
+
+// DefaultMaxValuesSize is the max total size of non-file multipart fields parsed
+// by Context.FormFile, names included, same as url-encoded forms of net/http.
+const DefaultMaxValuesSize = 10 << 20
+
+// UploadField declares a field of multipart uploads.
+type UploadField struct {
+	Name        string
+	Description string
+	// File is true for file fields.
+	File     bool
+	Required bool
+	// MaxSize is the max bytes of each part, unlimited for files and
+	// DefaultMaxValueSize for values if 0.
+	MaxSize int64
+	// MaxCount is the max parts of this field, 1 if 0.
+	MaxCount int
+	// Types are allowed media types of files sniffed from content, like
+	// "image/png" or "image/*". Any type is allowed if empty.
+	Types []string
+}
+
+// Upload is a Filter applying limits of its fields to multipart uploads read by
+// Context.FormFile or Context.MultipartReader. Routes filtered by it are documented
+// with formData parameters of the fields as well.
+type Upload struct {
+	Fields []UploadField
+	// MaxFiles is the max files of all fields, unlimited if 0.
+	MaxFiles int
+	// MaxValuesSize is the max total size of non-file fields kept in memory by
+	// Context.FormFile, DefaultMaxValuesSize if 0.
+	MaxValuesSize int64
+	// Strict rejects fields not declared.
+	Strict bool
+	// TempDir is where Context.FormFile stores files, os.TempDir() if empty.
+	TempDir string
+}
+
+// Filter implements Filter interface
+func (u *Upload) Filter(ctx *Context, next Handler) {
+	ctx.upload = u
+	next.Serve(ctx)
+}
+
+func (u *Upload) docOperation(method Method, op *swagger.Operation) {
+	op.DocConsumes("multipart/form-data")
+	for _, f := range u.Fields {
+		typ := "string"
+		if f.File {
+			typ = "file"
+		}
+		op.DocParameter(swagger.Parameter{
+			Name:        f.Name,
+			In:          "formData",
+			Description: f.Description,
+			Required:    f.Required,
+			Items:       swagger.Items{Type: typ},
+		})
+	}
+}
+
+func (u *Upload) maxValuesSize() int64 {
+	if u == nil || u.MaxValuesSize == 0 {
+		return DefaultMaxValuesSize
+	}
+	return u.MaxValuesSize
+}
+
+func (u *Upload) field(name string) *UploadField {
+	if u == nil {
+		return nil
+	}
+	for i := range u.Fields {
+		if u.Fields[i].Name == name {
+			return &u.Fields[i]
+		}
+	}
+	return nil
+}
+
+// Part is a part of multipart uploads limited by Upload.
+type Part struct {
+	*multipart.Part
+	// ContentType is the media type sniffed from content of file parts.
+	ContentType string
+
+	r io.Reader
+}
+
+// Read reads the content of part, failed with *Error of 413 if it's too large.
+func (p *Part) Read(b []byte) (int, error) {
+	return p.r.Read(b)
+}
+
+// IsFile returns if the part is a file.
+func (p *Part) IsFile() bool {
+	return p.FileName() != ""
+}
+
+type limitedReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+	name string
+	err  error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.max {
+		n -= int(l.read - l.max)
+		if n < 0 {
+			n = 0
+		}
+		l.err = NewError(http.StatusRequestEntityTooLarge, "field "+l.name+" too large")
+		return n, l.err
+	}
+	return n, err
+}
+
+// PartReader iterates parts of multipart uploads without buffering them.
+type PartReader struct {
+	mr     *multipart.Reader
+	upload *Upload
+	counts map[string]int
+	files  int
+}
+
+// MultipartReader returns PartReader of the request body, limited by Upload
+// filtering the request if any.
+func (ctx *Context) MultipartReader() (*PartReader, error) {
+	mediaType, params, err := mime.ParseMediaType(ctx.Request.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, NewError(http.StatusUnsupportedMediaType, "multipart/form-data expected")
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, NewError(http.StatusBadRequest, "missing multipart boundary")
+	}
+	if err := ctx.prepareBody(); err != nil {
+		return nil, err
+	}
+	return &PartReader{
+		mr:     multipart.NewReader(ctx.Request.Body, boundary),
+		upload: ctx.upload,
+		counts: make(map[string]int),
+	}, nil
+}
+
+// Next returns the next part, or io.EOF if there's no more. Errors of limits are
+// *Error, which could be responded by HandleError.
+func (pr *PartReader) Next() (*Part, error) {
+	p, err := pr.mr.NextPart()
+	if err == io.EOF {
+		return nil, pr.checkRequired()
+	}
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	name := p.FormName()
+	field := pr.upload.field(name)
+	if field == nil && pr.upload != nil && pr.upload.Strict {
+		return nil, NewError(http.StatusBadRequest, "unexpected field "+name)
+	}
+	pr.counts[name]++
+	if field != nil {
+		max := field.MaxCount
+		if max == 0 {
+			max = 1
+		}
+		if pr.counts[name] > max {
+			return nil, NewError(http.StatusBadRequest, "too many parts of field "+name)
+		}
+	}
+	part := &Part{Part: p, r: p}
+	maxSize := int64(0)
+	if field != nil {
+		maxSize = field.MaxSize
+	}
+	if part.IsFile() {
+		pr.files++
+		if pr.upload != nil && pr.upload.MaxFiles > 0 && pr.files > pr.upload.MaxFiles {
+			return nil, NewError(http.StatusBadRequest, "too many files")
+		}
+		br := bufio.NewReaderSize(p, 512)
+		head, err := br.Peek(512)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, bodyError(err)
+		}
+		part.ContentType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+		if field != nil && !matchMediaTypes(field.Types, part.ContentType) {
+			return nil, NewError(http.StatusUnsupportedMediaType, "type "+part.ContentType+" of field "+name+" not allowed")
+		}
+		part.r = br
+	} else if maxSize == 0 {
+		maxSize = DefaultMaxValueSize
+	}
+	if maxSize > 0 {
+		part.r = &limitedReader{r: io.LimitReader(part.r, maxSize+1), max: maxSize, name: name}
+	}
+	return part, nil
+}
+
+func (pr *PartReader) checkRequired() error {
+	if pr.upload == nil {
+		return io.EOF
+	}
+	for _, f := range pr.upload.Fields {
+		if f.Required && pr.counts[f.Name] == 0 {
+			return NewError(http.StatusBadRequest, "missing field "+f.Name)
+		}
+	}
+	return io.EOF
+}
+
+func matchMediaTypes(patterns []string, mediaType string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if pattern == mediaType || pattern == "*/*" ||
+			strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, pattern[:len(pattern)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormFile is a file of multipart uploads stored in a temporary file, which is
+// removed when the request ends.
+type FormFile struct {
+	Field    string
+	Filename string
+	// ContentType is the media type sniffed from content.
+	ContentType string
+	Size        int64
+	// Path is the path of the temporary file.
+	Path string
+}
+
+// Open opens the file for reading.
+func (f *FormFile) Open() (*os.File, error) {
+	return os.Open(f.Path)
+}
+
+// FormFile returns the first file of field name. Files are streamed to temporary
+// files of Upload.TempDir at the first call, and values are parsed into
+// Request.PostForm and Request.Form.
+func (ctx *Context) FormFile(name string) (*FormFile, error) {
+	files, err := ctx.FormFiles(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, http.ErrMissingFile
+	}
+	return files[0], nil
+}
+
+// FormFiles returns all files of field name like FormFile.
+// The error of parsing is returned by later calls as well.
+func (ctx *Context) FormFiles(name string) ([]*FormFile, error) {
+	if ctx.formFiles == nil && ctx.formErr == nil {
+		ctx.formFiles, ctx.formErr = ctx.parseUploads()
+	}
+	if ctx.formErr != nil {
+		return nil, ctx.formErr
+	}
+	return ctx.formFiles[name], nil
+}
+
+func (ctx *Context) parseUploads() (map[string][]*FormFile, error) {
+	pr, err := ctx.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]*FormFile)
+	budget := ctx.upload.maxValuesSize()
+	req := ctx.Request
+	if req.PostForm == nil {
+		req.PostForm = make(url.Values)
+	}
+	if req.Form == nil {
+		req.Form = req.URL.Query()
+	}
+	for {
+		part, err := pr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := part.FormName()
+		if !part.IsFile() {
+			budget -= int64(len(name))
+			data, err := io.ReadAll(io.LimitReader(part, budget+1))
+			if err != nil {
+				return nil, bodyError(err)
+			}
+			if budget -= int64(len(data)); budget < 0 {
+				return nil, NewError(http.StatusRequestEntityTooLarge, "form values too large")
+			}
+			req.PostForm.Add(name, string(data))
+			req.Form.Add(name, string(data))
+			continue
+		}
+		file, err := ctx.saveTempFile(part)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = append(files[name], file)
+	}
+}
+
+func (ctx *Context) saveTempFile(part *Part) (*FormFile, error) {
+	dir := ""
+	if ctx.upload != nil {
+		dir = ctx.upload.TempDir
+	}
+	f, err := os.CreateTemp(dir, "hador-upload-")
+	if err != nil {
+		return nil, err
+	}
+	ctx.tempFiles = append(ctx.tempFiles, f.Name())
+	size, err := io.Copy(f, part)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	return &FormFile{
+		Field:       part.FormName(),
+		Filename:    part.FileName(),
+		ContentType: part.ContentType,
+		Size:        size,
+		Path:        f.Name(),
+	}, nil
+}
+
+// removeTempFiles removes temporary files created by FormFile.
+func (ctx *Context) removeTempFiles() {
+	for _, name := range ctx.tempFiles {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			ctx.Logger.Warning("failed to remove temp file %s: %s", name, err)
+		}
+	}
+	ctx.tempFiles = ctx.tempFiles[:0]
+}
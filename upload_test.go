@@ -0,0 +1,202 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A")
+
+func TestUpload(t *testing.T) {
+	convey.Convey("Given Hador with uploads", t, func() {
+		h := New()
+		upload := &Upload{
+			Fields: []UploadField{
+				{Name: "avatar", File: true, Required: true, MaxSize: 64, Types: []string{"image/*"}},
+				{Name: "title", Description: "title of avatar", MaxSize: 8},
+			},
+			Strict:  true,
+			TempDir: t.TempDir(),
+		}
+		var saved string
+		h.Post("/avatar", func(ctx *Context) {
+			f, err := ctx.FormFile("avatar")
+			if err != nil {
+				ctx.HandleError(err)
+				return
+			}
+			saved = f.Path
+			r, _ := f.Open()
+			defer r.Close()
+			data, _ := io.ReadAll(r)
+			ctx.WriteString(ctx.Request.PostForm.Get("title") + " " + f.Filename + " " + f.ContentType + " " + string(data[1:4]))
+		}, upload)
+		h.Post("/panic", func(ctx *Context) {
+			ctx.FormFile("avatar")
+			panic("boom")
+		}, upload)
+		h.Post("/values", func(ctx *Context) {
+			if _, err := ctx.FormFiles("any"); err != nil {
+				ctx.HandleError(err)
+				return
+			}
+			ctx.WriteString(ctx.Request.PostForm.Get("a"))
+		}, &Upload{MaxValuesSize: 16, TempDir: upload.TempDir})
+		h.Post("/twice", func(ctx *Context) {
+			_, err1 := ctx.FormFiles("avatar")
+			files, err2 := ctx.FormFiles("avatar")
+			if err1 == nil || err2 == nil || files != nil {
+				ctx.WriteString("partial")
+				return
+			}
+			ctx.HandleError(err2)
+		}, upload)
+		h.Post("/stream", func(ctx *Context) {
+			pr, err := ctx.MultipartReader()
+			if err != nil {
+				ctx.HandleError(err)
+				return
+			}
+			var names []string
+			for {
+				part, err := pr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					ctx.HandleError(err)
+					return
+				}
+				data, _ := io.ReadAll(part)
+				names = append(names, part.FormName()+"="+string(data))
+			}
+			ctx.WriteString(strings.Join(names, ","))
+		})
+
+		type field struct {
+			name, filename string
+			content        []byte
+		}
+		serve := func(path string, fields ...field) *httptest.ResponseRecorder {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			for _, f := range fields {
+				var w io.Writer
+				if f.filename != "" {
+					w, _ = mw.CreateFormFile(f.name, f.filename)
+				} else {
+					w, _ = mw.CreateFormField(f.name)
+				}
+				w.Write(f.content)
+			}
+			mw.Close()
+			req, _ := http.NewRequest("POST", path, &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			return resp
+		}
+		png := append(append([]byte{}, pngHeader...), "data"...)
+
+		convey.Convey("files are stored and removed", func() {
+			resp := serve("/avatar", field{"title", "", []byte("me")}, field{"avatar", "me.png", png})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(resp.Body.String(), convey.ShouldEqual, "me me.png image/png PNG")
+			_, err := os.Stat(saved)
+			convey.So(os.IsNotExist(err), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("limits are applied", func() {
+			resp := serve("/avatar", field{"title", "", []byte("me")})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusBadRequest)
+			resp = serve("/avatar", field{"avatar", "me.txt", []byte("text")})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusUnsupportedMediaType)
+			resp = serve("/avatar", field{"avatar", "me.png", append(png, make([]byte, 64)...)})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusRequestEntityTooLarge)
+			resp = serve("/avatar", field{"title", "", []byte("too long title")}, field{"avatar", "me.png", png})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusRequestEntityTooLarge)
+			resp = serve("/avatar", field{"avatar", "a.png", png}, field{"avatar", "b.png", png})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusBadRequest)
+			resp = serve("/avatar", field{"other", "", nil}, field{"avatar", "a.png", png})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusBadRequest)
+			entries, _ := os.ReadDir(upload.TempDir)
+			convey.So(entries, convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("files are removed if handler panics", func() {
+			convey.So(func() { serve("/panic", field{"avatar", "me.png", png}) }, convey.ShouldPanicWith, "boom")
+			entries, _ := os.ReadDir(upload.TempDir)
+			convey.So(entries, convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("values are limited in total", func() {
+			resp := serve("/values", field{"a", "", []byte("1234")}, field{"b", "", []byte("5678")})
+			convey.So(resp.Body.String(), convey.ShouldEqual, "1234")
+			resp = serve("/values", field{"a", "", []byte("1234")}, field{"b", "", []byte("5678")}, field{"c", "", []byte("123456")})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusRequestEntityTooLarge)
+		})
+
+		convey.Convey("reads after the limit fail", func() {
+			l := &limitedReader{r: strings.NewReader("too long"), max: 3, name: "a"}
+			buf := make([]byte, 16)
+			n, err := l.Read(buf)
+			convey.So(n, convey.ShouldEqual, 3)
+			convey.So(err, convey.ShouldNotBeNil)
+			n, err2 := l.Read(buf)
+			convey.So(n, convey.ShouldEqual, 0)
+			convey.So(err2, convey.ShouldEqual, err)
+		})
+
+		convey.Convey("parse error is kept", func() {
+			resp := serve("/twice", field{"avatar", "a.png", png}, field{"avatar", "b.png", png})
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusBadRequest)
+		})
+
+		convey.Convey("parts could be streamed", func() {
+			resp := serve("/stream", field{"a", "", []byte("1")}, field{"b", "b.txt", []byte("2")})
+			convey.So(resp.Body.String(), convey.ShouldEqual, "a=1,b=2")
+
+			req, _ := http.NewRequest("POST", "/stream", strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			resp = httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			convey.So(resp.Code, convey.ShouldEqual, http.StatusUnsupportedMediaType)
+		})
+
+		convey.Convey("fields are documented", func() {
+			op := h.routes().root.find("/avatar").leaves[POST].SwaggerOperation()
+			convey.So(op.Consumes, convey.ShouldResemble, []string{"multipart/form-data"})
+			convey.So(op.Parameters, convey.ShouldHaveLength, 2)
+			convey.So(op.Parameters[0].In, convey.ShouldEqual, "formData")
+			convey.So(op.Parameters[0].Type, convey.ShouldEqual, "file")
+			convey.So(op.Parameters[0].Required, convey.ShouldBeTrue)
+			convey.So(op.Parameters[1].Type, convey.ShouldEqual, "string")
+			convey.So(op.Parameters[1].Description, convey.ShouldEqual, "title of avatar")
+		})
+	})
+}
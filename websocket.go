@@ -0,0 +1,583 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types of WebSocket defined in RFC 6455.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// Close codes of WebSocket defined in RFC 6455.
+const (
+	CloseNormalClosure    = 1000
+	CloseGoingAway        = 1001
+	CloseProtocolError    = 1002
+	CloseUnsupportedData  = 1003
+	CloseNoStatusReceived = 1005
+	CloseInvalidPayload   = 1007
+	ClosePolicyViolation  = 1008
+	CloseMessageTooBig    = 1009
+	CloseInternalError    = 1011
+)
+
+// DefaultReadLimit is the max size of messages read by Conn if Upgrader.ReadLimit is 0.
+const DefaultReadLimit = 1 << 20
+
+const (
+	websocketGUID     = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	deflateExtension  = "permessage-deflate"
+	deflateTail       = "\x00\x00\xff\xff"
+	maxControlPayload = 125
+)
+
+// CloseError is returned by Conn.ReadMessage when the connection is closed by the
+// peer, or due to violations of the protocol.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket closed: %d %s", e.Code, e.Text)
+}
+
+var errCloseSent = errors.New("websocket close sent")
+
+// Upgrader upgrades requests to WebSocket. It's a Filter as well, which applies
+// itself to WebSocket routes it filters instead of DefaultUpgrader.
+type Upgrader struct {
+	// ReadLimit is the max size of messages read, DefaultReadLimit if 0.
+	ReadLimit int64
+	// EnableCompression negotiates permessage-deflate with clients supporting it.
+	EnableCompression bool
+	// Subprotocols are supported subprotocols in order of preference.
+	Subprotocols []string
+	// CheckOrigin returns if the Origin of request is allowed. Only requests from
+	// the same host or without Origin are allowed if nil.
+	CheckOrigin func(req *http.Request) bool
+}
+
+// DefaultUpgrader is the Upgrader used if no one filters the request.
+var DefaultUpgrader = &Upgrader{}
+
+// Filter implements Filter interface
+func (u *Upgrader) Filter(ctx *Context, next Handler) {
+	ctx.upgrader = u
+	next.Serve(ctx)
+}
+
+// WebSocket adds a GET route upgrading requests to WebSocket after filters, and
+// serving the connection by handler, which is closed when handler returns.
+func (r RouterFunc) WebSocket(pattern string, handler func(*Context, *Conn), filters ...Filter) *Leaf {
+	return r.Get(pattern, HandlerFunc(func(ctx *Context) {
+		conn, err := ctx.Upgrade()
+		if err != nil {
+			return
+		}
+		defer conn.Close(CloseNormalClosure, "")
+		handler(ctx, conn)
+	}), filters...)
+}
+
+// Upgrade upgrades the request to WebSocket by the Upgrader filtering it, or
+// DefaultUpgrader. Failures are responded by OnError before returned.
+func (ctx *Context) Upgrade() (*Conn, error) {
+	u := ctx.upgrader
+	if u == nil {
+		u = DefaultUpgrader
+	}
+	return u.upgrade(ctx)
+}
+
+func (u *Upgrader) fail(ctx *Context, status int, message string) error {
+	err := NewError(status, message)
+	ctx.OnError(status, err)
+	return err
+}
+
+func (u *Upgrader) upgrade(ctx *Context) (*Conn, error) {
+	req := ctx.Request
+	if req.Method != "GET" ||
+		!headerHasToken(req.Header, "Connection", "upgrade") ||
+		!headerHasToken(req.Header, "Upgrade", "websocket") {
+		ctx.SetHeader("Upgrade", "websocket")
+		return nil, u.fail(ctx, http.StatusUpgradeRequired, "websocket upgrade expected")
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		ctx.SetHeader("Sec-WebSocket-Version", "13")
+		return nil, u.fail(ctx, http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if nonce, err := base64.StdEncoding.DecodeString(key); err != nil || len(nonce) != 16 {
+		return nil, u.fail(ctx, http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(req) {
+		return nil, u.fail(ctx, http.StatusForbidden, "origin not allowed")
+	}
+	hijacker, ok := ctx.Response.(http.Hijacker)
+	if !ok {
+		return nil, u.fail(ctx, http.StatusInternalServerError, "websocket unsupported")
+	}
+
+	protocol := u.selectSubprotocol(req)
+	compress := u.EnableCompression && offersDeflate(req.Header)
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	buf.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	if protocol != "" {
+		buf.WriteString("Sec-WebSocket-Protocol: " + protocol + "\r\n")
+	}
+	if compress {
+		buf.WriteString("Sec-WebSocket-Extensions: " + deflateExtension +
+			"; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	// headers set by filters, e.g. cookies
+	ctx.Response.Header().Write(&buf)
+	buf.WriteString("\r\n")
+
+	netConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	recordHijacked(ctx.Response, http.StatusSwitchingProtocols)
+	if _, err := netConn.Write(buf.Bytes()); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	c := newConn(netConn, brw.Reader, true)
+	c.Subprotocol = protocol
+	c.compress = compress
+	if u.ReadLimit > 0 {
+		c.readLimit = u.ReadLimit
+	}
+	return c, nil
+}
+
+func (u *Upgrader) selectSubprotocol(req *http.Request) string {
+	for _, value := range req.Header["Sec-Websocket-Protocol"] {
+		for _, offer := range strings.Split(value, ",") {
+			offer = strings.TrimSpace(offer)
+			for _, protocol := range u.Subprotocols {
+				if offer == protocol {
+					return protocol
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func sameOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, req.Host)
+}
+
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header[name] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func offersDeflate(header http.Header) bool {
+	for _, value := range header["Sec-Websocket-Extensions"] {
+		for _, ext := range strings.Split(value, ",") {
+			if i := strings.IndexByte(ext, ';'); i >= 0 {
+				ext = ext[:i]
+			}
+			if strings.TrimSpace(ext) == deflateExtension {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Conn is a WebSocket connection. Messages should be read by one goroutine, and
+// could be written by many.
+type Conn struct {
+	// Subprotocol is the subprotocol negotiated.
+	Subprotocol string
+
+	conn      net.Conn
+	br        *bufio.Reader
+	server    bool
+	compress  bool
+	readLimit int64
+	readErr   error
+	onPong    func(data []byte)
+
+	wmu       sync.Mutex
+	closeSent bool
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, server bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	return &Conn{conn: conn, br: br, server: server, readLimit: DefaultReadLimit}
+}
+
+// SetReadLimit sets the max size of messages read.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetPongHandler sets handler called with payload of pongs received by ReadMessage.
+// Pings are answered automatically.
+func (c *Conn) SetPongHandler(handler func(data []byte)) {
+	c.onPong = handler
+}
+
+// SetReadDeadline sets deadline of reading from the underlying connection.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets deadline of writing to the underlying connection.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// RemoteAddr returns the remote address of the connection.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+type frameHeader struct {
+	fin    bool
+	rsv1   bool
+	opcode int
+	length int64
+	mask   []byte
+}
+
+// ReadMessage reads the next text or binary message. Control frames received
+// meanwhile are handled, and a *CloseError is returned once the connection is
+// closed by the peer or due to violations.
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	var compressed bool
+	for {
+		h, err := c.readFrameHeader()
+		if err != nil {
+			return 0, nil, c.readFailed(err)
+		}
+		if h.opcode < CloseMessage && int64(len(data))+h.length > c.readLimit {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too large")
+		}
+		payload := make([]byte, h.length)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return 0, nil, c.readFailed(err)
+		}
+		if h.mask != nil {
+			maskBytes(h.mask, payload)
+		}
+
+		switch h.opcode {
+		case PingMessage:
+			if err := c.writeFrame(PongMessage, payload, false); err != nil && err != errCloseSent {
+				return 0, nil, c.readFailed(err)
+			}
+			continue
+		case PongMessage:
+			if c.onPong != nil {
+				c.onPong(payload)
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.closeReceived(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "continuation frame expected")
+			}
+			messageType, compressed = h.opcode, h.rsv1
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+		data = append(data, payload...)
+		if h.fin {
+			break
+		}
+	}
+	if compressed {
+		if data, err = c.inflate(data); err != nil {
+			return 0, nil, err
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, c.fail(CloseInvalidPayload, "invalid UTF-8")
+	}
+	return messageType, data, nil
+}
+
+func (c *Conn) readFrameHeader() (*frameHeader, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+		return nil, err
+	}
+	h := &frameHeader{
+		fin:    b[0]&0x80 != 0,
+		rsv1:   b[0]&0x40 != 0,
+		opcode: int(b[0] & 0x0f),
+		length: int64(b[1] & 0x7f),
+	}
+	control := h.opcode >= CloseMessage
+	switch {
+	case b[0]&0x30 != 0:
+		return nil, c.fail(CloseProtocolError, "unexpected reserved bits")
+	case h.rsv1 && (!c.compress || control || h.opcode == continuationFrame):
+		return nil, c.fail(CloseProtocolError, "unexpected compressed frame")
+	case control && (!h.fin || h.length > maxControlPayload):
+		return nil, c.fail(CloseProtocolError, "invalid control frame")
+	case (b[1]&0x80 != 0) != c.server:
+		return nil, c.fail(CloseProtocolError, "invalid masking")
+	}
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+			return nil, err
+		}
+		h.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, b[:8]); err != nil {
+			return nil, err
+		}
+		if b[0]&0x80 != 0 {
+			return nil, c.fail(CloseProtocolError, "invalid length")
+		}
+		h.length = int64(binary.BigEndian.Uint64(b[:8]))
+	}
+	if c.server {
+		h.mask = make([]byte, 4)
+		if _, err := io.ReadFull(c.br, h.mask); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+func (c *Conn) inflate(data []byte) ([]byte, error) {
+	r := flate.NewReader(io.MultiReader(
+		bytes.NewReader(data),
+		// the stripped tail, and a final empty block to end the stream
+		strings.NewReader(deflateTail+"\x01\x00\x00\xff\xff"),
+	))
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, c.readLimit+1))
+	if err != nil {
+		return nil, c.fail(CloseInvalidPayload, "invalid compressed data")
+	}
+	if int64(len(out)) > c.readLimit {
+		return nil, c.fail(CloseMessageTooBig, "message too large")
+	}
+	return out, nil
+}
+
+// closeReceived answers the close frame of the peer.
+func (c *Conn) closeReceived(payload []byte) error {
+	e := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		e.Code = int(binary.BigEndian.Uint16(payload))
+		e.Text = string(payload[2:])
+		if !validCloseCode(e.Code) || !utf8.Valid(payload[2:]) {
+			return c.fail(CloseProtocolError, "invalid close frame")
+		}
+	}
+	echo := e.Code
+	if echo == CloseNoStatusReceived {
+		echo = CloseNormalClosure
+	}
+	c.writeClose(echo, "")
+	c.readErr = e
+	return e
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// fail closes the connection due to violations.
+func (c *Conn) fail(code int, text string) error {
+	c.writeClose(code, text)
+	c.conn.Close()
+	c.readErr = &CloseError{Code: code, Text: text}
+	return c.readErr
+}
+
+func (c *Conn) readFailed(err error) error {
+	if c.readErr == nil {
+		c.readErr = err
+	}
+	return c.readErr
+}
+
+// WriteMessage writes a text or binary message, compressed if negotiated.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("invalid message type %d", messageType)
+	}
+	if !c.compress {
+		return c.writeFrame(messageType, data, false)
+	}
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	fw.Write(data)
+	fw.Flush()
+	return c.writeFrame(messageType, bytes.TrimSuffix(buf.Bytes(), []byte(deflateTail)), true)
+}
+
+// WriteText writes text as a text message.
+func (c *Conn) WriteText(text string) error {
+	return c.WriteMessage(TextMessage, []byte(text))
+}
+
+// Ping sends a ping with data no longer than 125 bytes.
+func (c *Conn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return errors.New("ping payload too large")
+	}
+	return c.writeFrame(PingMessage, data, false)
+}
+
+// Close sends a close frame with code and text if not sent yet, and closes the
+// underlying connection.
+func (c *Conn) Close(code int, text string) error {
+	err := c.writeClose(code, text)
+	if cerr := c.conn.Close(); err == nil || err == errCloseSent {
+		err = cerr
+	}
+	return err
+}
+
+func (c *Conn) writeClose(code int, text string) error {
+	if len(text) > maxControlPayload-2 {
+		text = text[:maxControlPayload-2]
+	}
+	payload := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], text)
+	return c.writeFrame(CloseMessage, payload, false)
+}
+
+func (c *Conn) writeFrame(opcode int, payload []byte, rsv1 bool) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return errCloseSent
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+	frame := make([]byte, 0, 14+len(payload))
+	b0 := byte(0x80 | opcode)
+	if rsv1 {
+		b0 |= 0x40
+	}
+	frame = append(frame, b0)
+	var maskBit byte
+	if !c.server {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	start := len(frame)
+	if !c.server {
+		var mask [4]byte
+		rand.Read(mask[:])
+		frame = append(frame, mask[:]...)
+		start += 4
+		frame = append(frame, payload...)
+		maskBytes(mask[:], frame[start:])
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func maskBytes(mask []byte, data []byte) {
+	for i := range data {
+		data[i] ^= mask[i&3]
+	}
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright 2016 Xuyuan Pang
+ * Author: Xuyuan Pang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hador
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+// dialWebSocket performs the opening handshake as a client.
+func dialWebSocket(server *httptest.Server, path string, header http.Header) (*Conn, *http.Response, error) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		return nil, nil, err
+	}
+	req, _ := http.NewRequest("GET", server.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Write(conn)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := newConn(conn, br, false)
+	c.compress = offersDeflate(resp.Header)
+	return c, resp, nil
+}
+
+func TestWebSocket(t *testing.T) {
+	convey.Convey("Given Hador serving WebSocket", t, func() {
+		h := New()
+		h.Group("/ws", func(r Router) {
+			r.WebSocket("/echo/{name}", func(ctx *Context, conn *Conn) {
+				conn.WriteText("hello " + ctx.Params().GetStringMust("name", "") + " " + conn.Subprotocol)
+				for {
+					typ, data, err := conn.ReadMessage()
+					if err != nil {
+						return
+					}
+					conn.WriteMessage(typ, data)
+				}
+			}, &Upgrader{ReadLimit: 1024, EnableCompression: true, Subprotocols: []string{"chat"}})
+		}, FilterFunc(func(ctx *Context, next Handler) {
+			if ctx.Request.URL.Query().Get("token") != "secret" {
+				ctx.OnError(http.StatusUnauthorized)
+				return
+			}
+			ctx.SetHeader("X-Filtered", "yes")
+			next.Serve(ctx)
+		}))
+		server := httptest.NewServer(h)
+		defer server.Close()
+
+		convey.Convey("messages are echoed", func() {
+			conn, resp, err := dialWebSocket(server, "/ws/echo/jack?token=secret", http.Header{
+				"Sec-Websocket-Protocol":   {"other, chat"},
+				"Sec-Websocket-Extensions": {"permessage-deflate; client_max_window_bits"},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusSwitchingProtocols)
+			convey.So(resp.Header.Get("Sec-WebSocket-Accept"), convey.ShouldEqual, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+			convey.So(resp.Header.Get("X-Filtered"), convey.ShouldEqual, "yes")
+			convey.So(conn.compress, convey.ShouldBeTrue)
+
+			typ, data, err := conn.ReadMessage()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(typ, convey.ShouldEqual, TextMessage)
+			convey.So(string(data), convey.ShouldEqual, "hello jack chat")
+
+			long := strings.Repeat("hador ", 100)
+			conn.WriteText(long)
+			_, data, _ = conn.ReadMessage()
+			convey.So(string(data), convey.ShouldEqual, long)
+
+			pong := make(chan string, 1)
+			conn.SetPongHandler(func(data []byte) { pong <- string(data) })
+			conn.Ping([]byte("ping"))
+			conn.WriteMessage(BinaryMessage, []byte{1, 2, 3})
+			typ, data, _ = conn.ReadMessage()
+			convey.So(typ, convey.ShouldEqual, BinaryMessage)
+			convey.So(data, convey.ShouldResemble, []byte{1, 2, 3})
+			convey.So(<-pong, convey.ShouldEqual, "ping")
+
+			conn.writeClose(CloseGoingAway, "bye")
+			_, _, err = conn.ReadMessage()
+			convey.So(err, convey.ShouldResemble, &CloseError{Code: CloseGoingAway})
+			conn.Close(CloseNormalClosure, "")
+		})
+
+		convey.Convey("read limit is applied", func() {
+			conn, _, err := dialWebSocket(server, "/ws/echo/jack?token=secret", nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(conn.compress, convey.ShouldBeFalse)
+			conn.ReadMessage()
+			conn.WriteMessage(BinaryMessage, make([]byte, 2048))
+			_, _, err = conn.ReadMessage()
+			convey.So(err, convey.ShouldResemble, &CloseError{Code: CloseMessageTooBig, Text: "message too large"})
+			conn.Close(CloseNormalClosure, "")
+		})
+
+		convey.Convey("status is recorded behind wrappers", func() {
+			statuses := make(chan int, 1)
+			h.Group("/wrapped", func(r Router) {
+				r.WebSocket("/", func(ctx *Context, conn *Conn) {
+					conn.Close(CloseNormalClosure, "")
+				})
+			}, FilterFunc(func(ctx *Context, next Handler) {
+				next.Serve(ctx)
+				statuses <- ctx.Response.Status()
+			}), NewCompression(), NewETag())
+			conn, resp, err := dialWebSocket(server, "/wrapped", http.Header{"Accept-Encoding": {"gzip"}})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusSwitchingProtocols)
+			conn.Close(CloseNormalClosure, "")
+			convey.So(<-statuses, convey.ShouldEqual, http.StatusSwitchingProtocols)
+		})
+
+		convey.Convey("filters run before upgrade", func() {
+			_, resp, err := dialWebSocket(server, "/ws/echo/jack", nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusUnauthorized)
+		})
+
+		convey.Convey("invalid handshakes are rejected", func() {
+			resp, err := http.Get(server.URL + "/ws/echo/jack?token=secret")
+			convey.So(err, convey.ShouldBeNil)
+			resp.Body.Close()
+			convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusUpgradeRequired)
+
+			_, resp, _ = dialWebSocket(server, "/ws/echo/jack?token=secret", http.Header{"Origin": {"http://evil.com"}})
+			convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusForbidden)
+
+			_, resp, _ = dialWebSocket(server, "/ws/echo/jack?token=secret", http.Header{"Sec-Websocket-Key": {"short"}})
+			convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}